@@ -2,17 +2,19 @@ module groupietracker
 
 go 1.25.0
 
-require github.com/gorilla/sessions v1.4.0
+require (
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/gorilla/sessions v1.4.0
+	github.com/jackc/pgx/v5 v5.8.0
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.18.0
+	golang.org/x/text v0.29.0
+)
 
 require (
-	github.com/go-sql-driver/mysql v1.7.1 // indirect
 	github.com/gorilla/securecookie v1.1.2 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
-	github.com/jackc/pgx/v5 v5.8.0 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	golang.org/x/crypto v0.18.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
-	golang.org/x/text v0.29.0 // indirect
 )
@@ -14,6 +14,9 @@ func main() {
 		log.Fatalf("migration base de données impossible: %v", err)
 	}
 	defer db.Close()
+	if err := src.LoadGeocodeCache(db); err != nil {
+		log.Printf("cache de géocodage non chargé: %v", err)
+	}
 
 	srv, err := src.NewServer()
 	if err != nil {
@@ -1,86 +1,224 @@
 package src
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"sort"
+	"sync"
+	"time"
 )
 
-func FetchArtistsData(client *http.Client) ([]Artist, error) {
-	var artists []Artist
-	if err := FetchJSON(client, ArtistsEndpoint, &artists); err != nil {
-		return nil, err
+// Facettes complémentaires d'un artiste, récupérées sur des endpoints
+// distincts de /artists.
+const (
+	FacetLocations = "locations"
+	FacetDates     = "dates"
+	FacetRelations = "relations"
+)
+
+// FetchReport résume une récupération du catalogue: la source utilisée et,
+// pour chaque facette indisponible, l'erreur rencontrée.
+type FetchReport struct {
+	Source   string
+	Degraded map[string]string
+	Invalid  []ConcertError
+}
+
+// DegradedFacets renvoie la liste triée des facettes en échec.
+func (r FetchReport) DegradedFacets() []string {
+	facets := make([]string, 0, len(r.Degraded))
+	for facet := range r.Degraded {
+		facets = append(facets, facet)
 	}
-	locMap, err := FetchLocations(client)
-	if err != nil {
-		return nil, err
+	sort.Strings(facets)
+	return facets
+}
+
+// FetchArtistsData interroge en parallèle les quatre jeux de données de la
+// source avec une échéance commune. Seul l'échec de /artists est bloquant:
+// si une autre facette manque, les artistes sont tout de même publiés et la
+// facette est signalée dans le rapport et dans Artist.MissingFacets.
+func FetchArtistsData(ctx context.Context, source ArtistSource) ([]Artist, FetchReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, RefreshTimeout)
+	defer cancel()
+
+	report := FetchReport{
+		Source:   source.Name(),
+		Degraded: make(map[string]string),
 	}
-	dateMap, err := FetchDates(client)
-	if err != nil {
-		return nil, err
+
+	var (
+		wg              sync.WaitGroup
+		artists         []Artist
+		locMap, dateMap map[int][]string
+		relMap          map[int]map[string][]string
+		artErr          error
+		locErr, dateErr error
+		relErr          error
+	)
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		artists, artErr = source.FetchArtists(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		locMap, locErr = source.FetchLocations(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		dateMap, dateErr = source.FetchDates(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		relMap, relErr = source.FetchRelations(ctx)
+	}()
+	wg.Wait()
+
+	if artErr != nil {
+		return nil, report, fmt.Errorf("récupération des artistes: %w", artErr)
 	}
-	relMap, err := FetchRelations(client)
-	if err != nil {
-		return nil, err
+	for facet, err := range map[string]error{
+		FacetLocations: locErr,
+		FacetDates:     dateErr,
+		FacetRelations: relErr,
+	} {
+		if err != nil {
+			report.Degraded[facet] = err.Error()
+			log.Printf("Source %s dégradée (%s): %v", report.Source, facet, err)
+		}
 	}
+
+	MergeArtistFacets(artists, locMap, dateMap, relMap)
+	report.Invalid = AttachConcerts(artists)
+	for _, invalid := range report.Invalid {
+		log.Printf("Concert ignoré: %v", invalid)
+	}
+	return artists, report, nil
+}
+
+// MergeArtistFacets rattache lieux, dates et relations à chaque artiste.
+// Une map nil signifie que la facette n'a pas pu être récupérée.
+func MergeArtistFacets(artists []Artist, locMap, dateMap map[int][]string, relMap map[int]map[string][]string) {
 	for i := range artists {
-		id := artists[i].ID
-		artists[i].Locations = locMap[id]
-		artists[i].ConcertDates = CleanDates(dateMap[id])
-		artists[i].DatesLocations = relMap[id]
+		art := &artists[i]
+		art.MissingFacets = nil
+		if locs, ok := locMap[art.ID]; ok {
+			art.Locations = locs
+		} else {
+			art.MissingFacets = append(art.MissingFacets, FacetLocations)
+		}
+		if dates, ok := dateMap[art.ID]; ok {
+			art.ConcertDates = CleanDates(dates)
+		} else {
+			art.MissingFacets = append(art.MissingFacets, FacetDates)
+		}
+		if rels, ok := relMap[art.ID]; ok {
+			art.DatesLocations = rels
+		} else {
+			art.MissingFacets = append(art.MissingFacets, FacetRelations)
+		}
 	}
-	return artists, nil
 }
 
-func FetchLocations(client *http.Client) (map[int][]string, error) {
+func FetchLocations(ctx context.Context, client *http.Client) (map[int][]string, error) {
 	var payload LocationsPayload
-	if err := FetchJSON(client, LocationsEndpoint, &payload); err != nil {
+	if err := FetchJSON(ctx, client, LocationsEndpoint, &payload); err != nil {
 		return nil, err
 	}
-	result := make(map[int][]string, len(payload.Index))
-	for _, entry := range payload.Index {
-		result[entry.ID] = entry.Locations
-	}
-	return result, nil
+	return payload.ByID(), nil
 }
 
-func FetchDates(client *http.Client) (map[int][]string, error) {
+func FetchDates(ctx context.Context, client *http.Client) (map[int][]string, error) {
 	var payload DatesPayload
-	if err := FetchJSON(client, DatesEndpoint, &payload); err != nil {
+	if err := FetchJSON(ctx, client, DatesEndpoint, &payload); err != nil {
 		return nil, err
 	}
-	result := make(map[int][]string, len(payload.Index))
-	for _, entry := range payload.Index {
-		result[entry.ID] = entry.Dates
-	}
-	return result, nil
+	return payload.ByID(), nil
 }
 
-func FetchRelations(client *http.Client) (map[int]map[string][]string, error) {
+func FetchRelations(ctx context.Context, client *http.Client) (map[int]map[string][]string, error) {
 	var payload RelationsPayload
-	if err := FetchJSON(client, RelationsEndpoint, &payload); err != nil {
+	if err := FetchJSON(ctx, client, RelationsEndpoint, &payload); err != nil {
 		return nil, err
 	}
-	result := make(map[int]map[string][]string, len(payload.Index))
-	for _, entry := range payload.Index {
-		result[entry.ID] = entry.DatesLocations
-	}
-	return result, nil
+	return payload.ByID(), nil
 }
 
-func FetchJSON(client *http.Client, url string, target interface{}) error {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// FetchJSON télécharge url et décode le JSON dans target. Les réponses sont
+// conservées dans responseCache: la requête est conditionnelle
+// (If-None-Match / If-Modified-Since), un 304 réutilise le corps en cache et,
+// si la source est injoignable, la dernière copie valide est servie.
+func FetchJSON(ctx context.Context, client *http.Client, url string, target interface{}) error {
+	cached, err := responseCache.Load(url)
+	if err != nil {
+		log.Printf("Cache HTTP ignoré: %v", err)
+		cached = nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Accept", "application/json")
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return decodeCachedFallback(cached, url, err, target)
 	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if cached == nil {
+			return fmt.Errorf("appel %s renvoie 304 sans copie en cache", url)
+		}
+		return json.Unmarshal(cached.Body, target)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("appel %s renvoie %d", url, resp.StatusCode)
+		return decodeCachedFallback(cached, url, fmt.Errorf("appel %s renvoie %d", url, resp.StatusCode), target)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return decodeCachedFallback(cached, url, err, target)
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return decodeCachedFallback(cached, url, fmt.Errorf("décodage %s: %w", url, err), target)
+	}
+	entry := &CachedResponse{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+		Body:         body,
+	}
+	if err := responseCache.Store(entry); err != nil {
+		log.Printf("Cache HTTP non enregistré pour %s: %v", url, err)
+	}
+	return nil
+}
+
+// decodeCachedFallback sert la dernière copie connue quand l'appel distant a
+// échoué, ou renvoie l'erreur d'origine s'il n'y en a pas.
+func decodeCachedFallback(cached *CachedResponse, url string, cause error, target interface{}) error {
+	if cached == nil {
+		return cause
+	}
+	if err := json.Unmarshal(cached.Body, target); err != nil {
+		return cause
 	}
-	return json.NewDecoder(resp.Body).Decode(target)
+	log.Printf("%s injoignable (%v), copie en cache du %s utilisée", url, cause, cached.FetchedAt.Format(time.RFC3339))
+	return nil
 }
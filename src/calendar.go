@@ -0,0 +1,173 @@
+package src
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarContentType est le type MIME des flux iCalendar (RFC 5545).
+const CalendarContentType = "text/calendar; charset=utf-8"
+
+// CalendarEvent est un concert publié dans un flux iCalendar.
+type CalendarEvent struct {
+	UID      string
+	Date     time.Time
+	Summary  string
+	Location string
+	URL      string
+	Geo      *Coordinates
+}
+
+// ConcertEvents convertit les concerts d'un artiste en événements d'une
+// journée. L'UID ne dépend que de l'artiste, du lieu et de la date, pour que
+// les applications de calendrier reconnaissent un concert d'une mise à jour
+// à l'autre.
+func ConcertEvents(art Artist, baseURL string) []CalendarEvent {
+	events := make([]CalendarEvent, 0, len(art.Concerts))
+	for _, c := range art.Concerts {
+		event := CalendarEvent{
+			UID:      fmt.Sprintf("concert-%d-%s-%s@groupietracker", art.ID, c.Raw, c.Date.Format("20060102")),
+			Date:     c.Date,
+			Summary:  art.Name + " — " + c.Location(),
+			Location: c.Location(),
+			URL:      baseURL + "/artist?id=" + strconv.Itoa(art.ID),
+		}
+		if entry, ok := CachedGeocode(c.Raw); ok && entry.Status != GeocodeFailed {
+			coords := entry.Coordinates
+			event.Geo = &coords
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// WriteCalendar écrit un VCALENDAR contenant les événements, avec les fins
+// de ligne CRLF et le pliage à 75 octets imposés par la RFC 5545.
+func WriteCalendar(w io.Writer, name string, events []CalendarEvent, now time.Time) error {
+	out := bufio.NewWriter(w)
+	line := func(content string) {
+		out.WriteString(foldICSLine(content))
+		out.WriteString("\r\n")
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Groupie Tracker//Concerts//FR")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICSText(name))
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + event.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + event.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICSText(event.Summary))
+		line("LOCATION:" + escapeICSText(event.Location))
+		if event.Geo != nil {
+			line(fmt.Sprintf("GEO:%.6f;%.6f", event.Geo.Latitude, event.Geo.Longitude))
+		}
+		if event.URL != "" {
+			line("URL:" + event.URL)
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return out.Flush()
+}
+
+// escapeICSText échappe une valeur TEXT (RFC 5545 §3.3.11).
+func escapeICSText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// foldICSLine coupe une ligne de plus de 75 octets sans séparer un
+// caractère UTF-8; les lignes de continuation commencent par un espace.
+func foldICSLine(content string) string {
+	const limit = 75
+	if len(content) <= limit {
+		return content
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// requestBaseURL renvoie le schéma et l'hôte de la requête, pour construire
+// des liens absolus.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// HandleArtistCalendar publie les concerts d'un artiste en iCalendar.
+func (s *Server) HandleArtistCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	art, ok := s.artistFromPath(w, r)
+	if !ok {
+		return
+	}
+	setDownloadHeaders(w, CalendarContentType, exportFilename(art, "ics"))
+	if err := WriteCalendar(w, art.Name+" · concerts", ConcertEvents(art, requestBaseURL(r)), time.Now()); err != nil {
+		log.Printf("Erreur écriture calendrier: %v", err)
+	}
+}
+
+// HandleFavoritesCalendar publie les concerts des artistes favoris d'un
+// utilisateur. Le jeton de l'URL remplace la session, que les applications
+// de calendrier ne transmettent pas.
+func (s *Server) HandleFavoritesCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := UserIDByCalendarToken(DB, r.PathValue("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	ids, err := ListFavoriteIDs(DB, userID)
+	if err != nil {
+		log.Printf("Erreur lecture favoris: %v", err)
+		http.Error(w, "Erreur lors de la récupération des favoris", http.StatusInternalServerError)
+		return
+	}
+	baseURL := requestBaseURL(r)
+	var events []CalendarEvent
+	for _, id := range ids {
+		if art, ok := s.FindArtist(id); ok {
+			events = append(events, ConcertEvents(art, baseURL)...)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Date.Before(events[j].Date)
+	})
+	w.Header().Set("Content-Type", CalendarContentType)
+	if err := WriteCalendar(w, "Mes artistes favoris · Groupie Tracker", events, time.Now()); err != nil {
+		log.Printf("Erreur écriture calendrier: %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types de changements détectés entre deux versions du catalogue.
+const (
+	ChangeArtistAdded   = "artist_added"
+	ChangeArtistRemoved = "artist_removed"
+	ChangeConcertAdded  = "concert_added"
+	ChangeLocationAdded = "location_added"
+	ChangeMemberAdded   = "member_added"
+	ChangeMemberRemoved = "member_removed"
+)
+
+// CatalogChange décrit une évolution du catalogue entre deux actualisations.
+type CatalogChange struct {
+	ID         int64     `json:"id"`
+	Kind       string    `json:"kind"`
+	ArtistID   int       `json:"artist_id"`
+	ArtistName string    `json:"artist_name"`
+	Detail     string    `json:"detail,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	Date       string    `json:"date,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Label renvoie une description lisible du changement.
+func (c CatalogChange) Label() string {
+	switch c.Kind {
+	case ChangeArtistAdded:
+		return "Nouvel artiste au catalogue"
+	case ChangeArtistRemoved:
+		return "Artiste retiré du catalogue"
+	case ChangeConcertAdded:
+		return fmt.Sprintf("Nouveau concert à %s le %s", FormatLocation(c.Location), FormatDate(c.Date))
+	case ChangeLocationAdded:
+		return fmt.Sprintf("Nouvelle ville de tournée : %s", FormatLocation(c.Location))
+	case ChangeMemberAdded:
+		return fmt.Sprintf("%s rejoint le groupe", c.Detail)
+	case ChangeMemberRemoved:
+		return fmt.Sprintf("%s quitte le groupe", c.Detail)
+	}
+	return c.Kind
+}
+
+// DiffCatalog compare deux versions du catalogue. Les facettes absentes de
+// l'une ou l'autre version (actualisation dégradée) ne sont pas comparées,
+// pour ne pas signaler de faux ajouts ou retraits.
+func DiffCatalog(prev, next []Artist) []CatalogChange {
+	now := time.Now()
+	before := make(map[int]Artist, len(prev))
+	for _, art := range prev {
+		before[art.ID] = art
+	}
+	seen := make(map[int]bool, len(next))
+
+	var changes []CatalogChange
+	for _, art := range next {
+		seen[art.ID] = true
+		old, ok := before[art.ID]
+		if !ok {
+			changes = append(changes, CatalogChange{Kind: ChangeArtistAdded, ArtistID: art.ID, ArtistName: art.Name, CreatedAt: now})
+			continue
+		}
+		base := CatalogChange{ArtistID: art.ID, ArtistName: art.Name, CreatedAt: now}
+
+		for _, member := range missingFrom(art.Members, old.Members) {
+			change := base
+			change.Kind, change.Detail = ChangeMemberAdded, member
+			changes = append(changes, change)
+		}
+		for _, member := range missingFrom(old.Members, art.Members) {
+			change := base
+			change.Kind, change.Detail = ChangeMemberRemoved, member
+			changes = append(changes, change)
+		}
+		if hasFacets(art, old, FacetLocations) {
+			for _, loc := range missingFrom(art.Locations, old.Locations) {
+				change := base
+				change.Kind, change.Location = ChangeLocationAdded, loc
+				changes = append(changes, change)
+			}
+		}
+		if hasFacets(art, old, FacetRelations) {
+			for _, loc := range sortedKeys(art.DatesLocations) {
+				for _, date := range missingFrom(CleanDates(art.DatesLocations[loc]), CleanDates(old.DatesLocations[loc])) {
+					change := base
+					change.Kind, change.Location, change.Date = ChangeConcertAdded, loc, date
+					changes = append(changes, change)
+				}
+			}
+		}
+	}
+	for _, art := range prev {
+		if !seen[art.ID] {
+			changes = append(changes, CatalogChange{Kind: ChangeArtistRemoved, ArtistID: art.ID, ArtistName: art.Name, CreatedAt: now})
+		}
+	}
+	return changes
+}
+
+// hasFacets indique si la facette était disponible dans les deux versions.
+func hasFacets(a, b Artist, facet string) bool {
+	for _, missing := range append(append([]string{}, a.MissingFacets...), b.MissingFacets...) {
+		if missing == facet {
+			return false
+		}
+	}
+	return true
+}
+
+// missingFrom renvoie les valeurs de values absentes de reference.
+func missingFrom(values, reference []string) []string {
+	known := make(map[string]bool, len(reference))
+	for _, v := range reference {
+		known[strings.TrimSpace(v)] = true
+	}
+	var result []string
+	for _, v := range values {
+		if !known[strings.TrimSpace(v)] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// catalogSnapshot est la version du catalogue conservée en base pour
+// détecter les changements, y compris ceux survenus pendant un arrêt du
+// serveur. Seuls les champs comparés par DiffCatalog y figurent.
+type catalogSnapshot struct {
+	ID             int                 `json:"id"`
+	Name           string              `json:"name"`
+	Members        []string            `json:"members"`
+	Locations      []string            `json:"locations"`
+	DatesLocations map[string][]string `json:"dates_locations"`
+	MissingFacets  []string            `json:"missing_facets,omitempty"`
+}
+
+// snapshotCatalog prépare la version à conserver. Une facette indisponible
+// lors de cette actualisation reprend sa valeur de la version précédente,
+// pour que ses changements soient détectés à la prochaine actualisation
+// complète.
+func snapshotCatalog(prev, next []Artist) []catalogSnapshot {
+	before := make(map[int]Artist, len(prev))
+	for _, art := range prev {
+		before[art.ID] = art
+	}
+	snapshot := make([]catalogSnapshot, 0, len(next))
+	for _, art := range next {
+		entry := catalogSnapshot{ID: art.ID, Name: art.Name, Members: art.Members, Locations: art.Locations, DatesLocations: art.DatesLocations}
+		old, known := before[art.ID]
+		for _, facet := range art.MissingFacets {
+			switch {
+			case !known || containsString(old.MissingFacets, facet):
+				entry.MissingFacets = append(entry.MissingFacets, facet)
+			case facet == FacetLocations:
+				entry.Locations = old.Locations
+			case facet == FacetRelations:
+				entry.DatesLocations = old.DatesLocations
+			}
+		}
+		snapshot = append(snapshot, entry)
+	}
+	return snapshot
+}
+
+// RecordCatalogChanges compare le catalogue à la version conservée en base,
+// enregistre les changements et remplace la version conservée, dans une
+// seule transaction. Le tout premier chargement ne produit aucun changement.
+func RecordCatalogChanges(db *sql.DB, artists []Artist) ([]CatalogChange, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("enregistrement changements: %w", err)
+	}
+	defer tx.Rollback()
+
+	var prev []Artist
+	var data string
+	err = tx.QueryRow(`SELECT data FROM catalog_snapshot WHERE id = 1 FOR UPDATE`).Scan(&data)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("lecture catalogue précédent: %w", err)
+	default:
+		var entries []catalogSnapshot
+		if err := json.Unmarshal([]byte(data), &entries); err != nil {
+			return nil, fmt.Errorf("lecture catalogue précédent: %w", err)
+		}
+		for _, e := range entries {
+			prev = append(prev, Artist{ID: e.ID, Name: e.Name, Members: e.Members, Locations: e.Locations, DatesLocations: e.DatesLocations, MissingFacets: e.MissingFacets})
+		}
+	}
+
+	var changes []CatalogChange
+	if len(prev) > 0 {
+		changes = DiffCatalog(prev, artists)
+	}
+	const query = `INSERT INTO catalog_changes (kind, artist_id, artist_name, detail, location, concert_date, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	for _, c := range changes {
+		if _, err := tx.Exec(query, c.Kind, c.ArtistID, c.ArtistName, c.Detail, c.Location, c.Date, c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("enregistrement changement: %w", err)
+		}
+	}
+
+	encoded, err := json.Marshal(snapshotCatalog(prev, artists))
+	if err != nil {
+		return nil, fmt.Errorf("enregistrement catalogue: %w", err)
+	}
+	const upsert = `INSERT INTO catalog_snapshot (id, data) VALUES (1, ?) ON DUPLICATE KEY UPDATE data = VALUES(data)`
+	if _, err := tx.Exec(upsert, encoded); err != nil {
+		return nil, fmt.Errorf("enregistrement catalogue: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("enregistrement changements: %w", err)
+	}
+	return changes, nil
+}
+
+// ListCatalogChanges renvoie une page de changements, du plus récent au plus
+// ancien, ainsi que le nombre total de changements.
+func ListCatalogChanges(db *sql.DB, limit, offset int) ([]CatalogChange, int, error) {
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM catalog_changes`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("comptage changements: %w", err)
+	}
+	rows, err := db.Query(`SELECT id, kind, artist_id, artist_name, detail, location, concert_date, created_at FROM catalog_changes ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("liste changements: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []CatalogChange
+	for rows.Next() {
+		var c CatalogChange
+		if err := rows.Scan(&c.ID, &c.Kind, &c.ArtistID, &c.ArtistName, &c.Detail, &c.Location, &c.Date, &c.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan changement: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, total, rows.Err()
+}
+
+// HandleWhatsNew affiche la page paginée des nouveautés du catalogue.
+func (s *Server) HandleWhatsNew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	page := min(parsePositiveInt(r.URL.Query().Get("page"), 1), MaxChangesPage)
+	changes, total, err := ListCatalogChanges(DB, ChangesPageSize, (page-1)*ChangesPageSize)
+	if err != nil {
+		log.Printf("Erreur lecture nouveautés: %v", err)
+		http.Error(w, "Erreur lors de la récupération des nouveautés", http.StatusInternalServerError)
+		return
+	}
+	data := WhatsNewPageData{
+		Changes: changes,
+		Page:    page,
+		Total:   total,
+		User:    currentUserProfile(r),
+	}
+	if page > 1 {
+		data.PrevPage = page - 1
+	}
+	if page*ChangesPageSize < total {
+		data.NextPage = page + 1
+	}
+	s.Render(w, "whats-new.html", data)
+}
+
+// HandleWhatsNewFeed renvoie les nouveautés du catalogue en JSON.
+func (s *Server) HandleWhatsNewFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	page := min(parsePositiveInt(r.URL.Query().Get("page"), 1), MaxChangesPage)
+	perPage := parsePositiveInt(r.URL.Query().Get("per_page"), ChangesPageSize)
+	if perPage > MaxChangesPageSize {
+		perPage = MaxChangesPageSize
+	}
+	changes, total, err := ListCatalogChanges(DB, perPage, (page-1)*perPage)
+	if err != nil {
+		log.Printf("Erreur lecture nouveautés: %v", err)
+		http.Error(w, "Erreur lors de la récupération des nouveautés", http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []CatalogChange{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"changes":  changes,
+	})
+}
+
+// parsePositiveInt lit un entier strictement positif, ou renvoie fallback.
+func parsePositiveInt(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,246 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// APIDateLayout est le format des dates de concert publiées par l'API.
+const APIDateLayout = "02-01-2006"
+
+// Concert est un concert d'un artiste, construit une seule fois lors de
+// l'actualisation à partir des relations dates/lieux de l'API.
+type Concert struct {
+	ArtistID int       `json:"artist_id"`
+	Date     time.Time `json:"date"`
+	City     string    `json:"city,omitempty"`
+	Region   string    `json:"region,omitempty"`
+	Country  string    `json:"country"` // code ISO 3166-1 alpha-2, vide si inconnu
+	Raw      string    `json:"raw"`     // clé de lieu de l'API, ex. "north_carolina-usa"
+}
+
+// Place renvoie la ville, ou la région quand l'API ne précise qu'un État ou
+// une province.
+func (c Concert) Place() string {
+	if c.City != "" {
+		return c.City
+	}
+	return c.Region
+}
+
+// Location renvoie le lieu formaté pour l'affichage.
+func (c Concert) Location() string {
+	return FormatLocation(c.Raw)
+}
+
+// ConcertError signale une entrée de l'API qui n'a pas pu être interprétée.
+type ConcertError struct {
+	ArtistID int    `json:"artist_id"`
+	Raw      string `json:"raw"`
+	Value    string `json:"value,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+func (e ConcertError) Error() string {
+	if e.Value != "" {
+		return fmt.Sprintf("artiste %d, %s (%s): %s", e.ArtistID, e.Raw, e.Value, e.Reason)
+	}
+	return fmt.Sprintf("artiste %d, %s: %s", e.ArtistID, e.Raw, e.Reason)
+}
+
+// ParseConcertDate interprète une date de l'API ("*23-08-2019").
+func ParseConcertDate(value string) (time.Time, error) {
+	cleaned := strings.TrimPrefix(strings.TrimSpace(value), "*")
+	return time.Parse(APIDateLayout, cleaned)
+}
+
+// ErrUnknownCountry signale un pays absent de countryCodes.
+var ErrUnknownCountry = errors.New("pays inconnu")
+
+// ParseLocation découpe une clé de lieu de l'API ("north_carolina-usa") en
+// ville ou région et code pays ISO. Pour un pays inconnu, la ville est tout
+// de même renvoyée avec un code pays vide et ErrUnknownCountry.
+func ParseLocation(raw string) (city, region, country string, err error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(raw)), "-")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("format ville-pays attendu")
+	}
+	place := Capitalize(strings.ReplaceAll(parts[0], "_", " "))
+	country, ok := countryCodes[parts[1]]
+	if !ok {
+		return place, "", "", fmt.Errorf("%w: %s", ErrUnknownCountry, parts[1])
+	}
+	if regions[country][parts[0]] {
+		return "", place, country, nil
+	}
+	return place, "", country, nil
+}
+
+// BuildConcerts construit les concerts typés d'un artiste, triés par date,
+// et collecte les entrées invalides au lieu de les ignorer silencieusement.
+// Un concert dans un pays inconnu est conservé, sans code pays.
+func BuildConcerts(art Artist) ([]Concert, []ConcertError) {
+	var concerts []Concert
+	var errs []ConcertError
+	for _, raw := range sortedKeys(art.DatesLocations) {
+		city, region, country, err := ParseLocation(raw)
+		if err != nil {
+			errs = append(errs, ConcertError{ArtistID: art.ID, Raw: raw, Reason: err.Error()})
+			if !errors.Is(err, ErrUnknownCountry) {
+				continue
+			}
+		}
+		for _, value := range art.DatesLocations[raw] {
+			date, err := ParseConcertDate(value)
+			if err != nil {
+				errs = append(errs, ConcertError{ArtistID: art.ID, Raw: raw, Value: value, Reason: "date invalide"})
+				continue
+			}
+			concerts = append(concerts, Concert{
+				ArtistID: art.ID,
+				Date:     date,
+				City:     city,
+				Region:   region,
+				Country:  country,
+				Raw:      raw,
+			})
+		}
+	}
+	sort.SliceStable(concerts, func(i, j int) bool {
+		return concerts[i].Date.Before(concerts[j].Date)
+	})
+	return concerts, errs
+}
+
+// AttachConcerts renseigne Artist.Concerts pour tout le catalogue et renvoie
+// l'ensemble des entrées invalides.
+func AttachConcerts(artists []Artist) []ConcertError {
+	var errs []ConcertError
+	for i := range artists {
+		concerts, artErrs := BuildConcerts(artists[i])
+		artists[i].Concerts = concerts
+		errs = append(errs, artErrs...)
+	}
+	return errs
+}
+
+// countryCodes associe les noms de pays de l'API à leur code ISO 3166-1.
+var countryCodes = map[string]string{
+	"argentina":            "AR",
+	"australia":            "AU",
+	"austria":              "AT",
+	"belarus":              "BY",
+	"belgium":              "BE",
+	"bolivia":              "BO",
+	"brazil":               "BR",
+	"bulgaria":             "BG",
+	"canada":               "CA",
+	"chile":                "CL",
+	"china":                "CN",
+	"colombia":             "CO",
+	"costa_rica":           "CR",
+	"croatia":              "HR",
+	"czech_republic":       "CZ",
+	"czechia":              "CZ",
+	"denmark":              "DK",
+	"ecuador":              "EC",
+	"egypt":                "EG",
+	"estonia":              "EE",
+	"finland":              "FI",
+	"france":               "FR",
+	"french_polynesia":     "PF",
+	"germany":              "DE",
+	"greece":               "GR",
+	"hong_kong":            "HK",
+	"hungary":              "HU",
+	"iceland":              "IS",
+	"india":                "IN",
+	"indonesia":            "ID",
+	"ireland":              "IE",
+	"israel":               "IL",
+	"italy":                "IT",
+	"japan":                "JP",
+	"latvia":               "LV",
+	"lithuania":            "LT",
+	"luxembourg":           "LU",
+	"malaysia":             "MY",
+	"mexico":               "MX",
+	"netherlands":          "NL",
+	"netherlands_antilles": "AN",
+	"new_caledonia":        "NC",
+	"new_zealand":          "NZ",
+	"norway":               "NO",
+	"peru":                 "PE",
+	"philippines":          "PH",
+	"poland":               "PL",
+	"portugal":             "PT",
+	"qatar":                "QA",
+	"romania":              "RO",
+	"russia":               "RU",
+	"saudi_arabia":         "SA",
+	"serbia":               "RS",
+	"singapore":            "SG",
+	"slovakia":             "SK",
+	"slovenia":             "SI",
+	"south_africa":         "ZA",
+	"south_korea":          "KR",
+	"spain":                "ES",
+	"sweden":               "SE",
+	"switzerland":          "CH",
+	"taiwan":               "TW",
+	"thailand":             "TH",
+	"turkey":               "TR",
+	"uk":                   "GB",
+	"ukraine":              "UA",
+	"united_arab_emirates": "AE",
+	"usa":                  "US",
+	"venezuela":            "VE",
+}
+
+// regions liste, par pays, les clés de l'API qui désignent un État ou une
+// province plutôt qu'une ville.
+var regions = map[string]map[string]bool{
+	"US": {
+		"alabama": true, "arizona": true, "california": true, "colorado": true,
+		"florida": true, "georgia": true, "illinois": true, "massachusetts": true,
+		"michigan": true, "minnesota": true, "missouri": true, "nevada": true,
+		"new_hampshire": true, "new_jersey": true, "north_carolina": true, "ohio": true,
+		"oregon": true, "pennsylvania": true, "south_carolina": true, "texas": true,
+		"utah": true, "washington": true,
+	},
+	"AU": {
+		"new_south_wales": true, "queensland": true, "victoria": true, "western_australia": true,
+	},
+	"CA": {
+		"alberta": true, "british_columbia": true, "ontario": true, "quebec": true,
+	},
+}
+
+// countryNames est l'index inverse de countryCodes (premier nom par ordre
+// alphabétique en cas d'alias).
+var countryNames = make(map[string]string, len(countryCodes))
+
+func init() {
+	names := make([]string, 0, len(countryCodes))
+	for name := range countryCodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, exists := countryNames[countryCodes[name]]; !exists {
+			countryNames[countryCodes[name]] = name
+		}
+	}
+}
+
+// CountryName renvoie le nom d'affichage d'un code ISO, dans le même format
+// que FormatLocation ("NEW ZEALAND").
+func CountryName(code string) string {
+	if name, ok := countryNames[code]; ok {
+		return strings.ToUpper(strings.ReplaceAll(name, "_", " "))
+	}
+	return code
+}
@@ -0,0 +1,227 @@
+package src
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterDateLayout est le format des champs de date des formulaires
+// (input type="date").
+const FilterDateLayout = "2006-01-02"
+
+// ConcertFilters restreint les concerts de la carte globale.
+type ConcertFilters struct {
+	From     time.Time
+	To       time.Time // inclus
+	Country  string    // code ISO
+	ArtistID int
+}
+
+// ParseConcertFilters lit les paramètres from, to, country et artist; une
+// valeur invalide est ignorée.
+func ParseConcertFilters(values url.Values) ConcertFilters {
+	var f ConcertFilters
+	if from, err := time.Parse(FilterDateLayout, values.Get("from")); err == nil {
+		f.From = from
+	}
+	if to, err := time.Parse(FilterDateLayout, values.Get("to")); err == nil {
+		f.To = to
+	}
+	f.Country = strings.ToUpper(strings.TrimSpace(values.Get("country")))
+	if id, err := strconv.Atoi(values.Get("artist")); err == nil && id > 0 {
+		f.ArtistID = id
+	}
+	return f
+}
+
+// Matches indique si un concert passe les filtres.
+func (f ConcertFilters) Matches(c Concert) bool {
+	if f.ArtistID != 0 && c.ArtistID != f.ArtistID {
+		return false
+	}
+	if f.Country != "" && c.Country != f.Country {
+		return false
+	}
+	if !f.From.IsZero() && c.Date.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && c.Date.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// Values renvoie les filtres sous forme de paramètres d'URL.
+func (f ConcertFilters) Values() url.Values {
+	values := url.Values{}
+	if from := f.FromValue(); from != "" {
+		values.Set("from", from)
+	}
+	if to := f.ToValue(); to != "" {
+		values.Set("to", to)
+	}
+	if f.Country != "" {
+		values.Set("country", f.Country)
+	}
+	if f.ArtistID != 0 {
+		values.Set("artist", strconv.Itoa(f.ArtistID))
+	}
+	return values
+}
+
+// FromValue et ToValue préremplissent les champs de date du formulaire.
+func (f ConcertFilters) FromValue() string {
+	if f.From.IsZero() {
+		return ""
+	}
+	return f.From.Format(FilterDateLayout)
+}
+
+func (f ConcertFilters) ToValue() string {
+	if f.To.IsZero() {
+		return ""
+	}
+	return f.To.Format(FilterDateLayout)
+}
+
+// GeoJSONFeatureCollection est un document GeoJSON (RFC 7946).
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry est un point: les coordonnées sont [longitude, latitude].
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// NewPointFeature construit un point GeoJSON.
+func NewPointFeature(coords Coordinates, properties map[string]interface{}) GeoJSONFeature {
+	return GeoJSONFeature{
+		Type: "Feature",
+		Geometry: GeoJSONGeometry{
+			Type:        "Point",
+			Coordinates: []float64{coords.Longitude, coords.Latitude},
+		},
+		Properties: properties,
+	}
+}
+
+// ConcertFeatures place les concerts filtrés de tout le catalogue, un point
+// par artiste et par lieu, à partir des coordonnées précalculées. Les lieux
+// non localisés sont comptés à part.
+func ConcertFeatures(artists []Artist, f ConcertFilters) (GeoJSONFeatureCollection, int) {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	unresolved := 0
+	for _, art := range artists {
+		if f.ArtistID != 0 && art.ID != f.ArtistID {
+			continue
+		}
+		var concerts []Concert
+		for _, c := range art.Concerts {
+			if f.Matches(c) {
+				concerts = append(concerts, c)
+			}
+		}
+		for _, loc := range LocationsWithCoords(concerts) {
+			if loc.Status != LocationResolved {
+				unresolved++
+				continue
+			}
+			collection.Features = append(collection.Features, NewPointFeature(loc.Coordinates, map[string]interface{}{
+				"artist_id": art.ID,
+				"artist":    art.Name,
+				"location":  loc.Location,
+				"dates":     loc.Dates,
+				"url":       "/artist?id=" + strconv.Itoa(art.ID),
+			}))
+		}
+	}
+	return collection, unresolved
+}
+
+// MapOptions liste les pays et artistes proposés par les filtres de la
+// carte, avec leur nombre de concerts.
+func MapOptions(artists []Artist, f ConcertFilters) (countries, artistOptions []FacetCount) {
+	byCountry := make(map[string]int)
+	for _, art := range artists {
+		if len(art.Concerts) == 0 {
+			continue
+		}
+		for _, c := range art.Concerts {
+			if c.Country != "" {
+				byCountry[c.Country]++
+			}
+		}
+		artistOptions = append(artistOptions, FacetCount{
+			Value:    strconv.Itoa(art.ID),
+			Label:    art.Name,
+			Count:    len(art.Concerts),
+			Selected: art.ID == f.ArtistID,
+		})
+	}
+	for code, count := range byCountry {
+		countries = append(countries, FacetCount{
+			Value:    code,
+			Label:    CountryName(code),
+			Count:    count,
+			Selected: code == f.Country,
+		})
+	}
+	sort.Slice(countries, func(i, j int) bool {
+		return countries[i].Label < countries[j].Label
+	})
+	sort.Slice(artistOptions, func(i, j int) bool {
+		return strings.ToLower(artistOptions[i].Label) < strings.ToLower(artistOptions[j].Label)
+	})
+	return countries, artistOptions
+}
+
+// HandleMap affiche la carte de tous les concerts du catalogue.
+func (s *Server) HandleMap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	filters := ParseConcertFilters(r.URL.Query())
+	artists := s.ListArtists()
+	collection, unresolved := ConcertFeatures(artists, filters)
+	countries, artistOptions := MapOptions(artists, filters)
+	geojsonURL := "/api/concerts.geojson"
+	if query := filters.Values().Encode(); query != "" {
+		geojsonURL += "?" + query
+	}
+	data := MapPageData{
+		Filters:    filters,
+		Countries:  countries,
+		Artists:    artistOptions,
+		GeoJSONURL: geojsonURL,
+		Count:      len(collection.Features),
+		Unresolved: unresolved,
+		User:       currentUserProfile(r),
+	}
+	s.Render(w, "map.html", data)
+}
+
+// HandleConcertsGeoJSON renvoie les concerts filtrés au format GeoJSON.
+func (s *Server) HandleConcertsGeoJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	collection, _ := ConcertFeatures(s.ListArtists(), ParseConcertFilters(r.URL.Query()))
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(collection)
+}
@@ -1,6 +1,7 @@
 package src
 
 import (
+	"log"
 	"os"
 	"time"
 )
@@ -14,6 +15,12 @@ const (
 	ServerAddress      = ":8080"
 	ReadHeaderTimeout  = 5 * time.Second
 	ClientTimeout      = 10 * time.Second
+	RefreshTimeout     = 15 * time.Second
+	RefreshRetryBase   = 30 * time.Second
+	RefreshStatusPath  = "/api/refresh/status"
+	ChangesPageSize    = 20
+	MaxChangesPageSize = 100
+	MaxChangesPage     = 10000
 	RefreshPath        = "/refresh"
 	StaticPrefix       = "/static/"
 	TemplatesDirectory = "templates/*.html"
@@ -30,11 +37,84 @@ const (
 	DefaultTicketPrice = 50.00
 )
 
+// Billetterie.
+const (
+	TicketCurrency         = "EUR"
+	MaxTicketsPerOrder     = 10
+	DefaultConcertCapacity = 500
+	SeatHoldTTL            = 15 * time.Minute
+	HoldSweepInterval      = time.Minute
+)
+
+// Recherche instantanée et pagination du catalogue.
+const (
+	DefaultSuggestLimit = 8
+	MaxSuggestLimit     = 25
+	ArtistsPageSize     = 12
+	MaxArtistsPageSize  = 60
+)
+
+// Politique d'utilisation de Nominatim: délai appliqué après un 429 sans
+// Retry-After, et attente maximale avant un nouvel essai immédiat.
+const (
+	NominatimBackoff      = time.Minute
+	NominatimMaxRetryWait = 5 * time.Second
+)
+
+// Un token PayPal est renouvelé PayPalTokenMargin avant son expiration; sa
+// demande est abandonnée après PayPalTokenTimeout.
+const (
+	PayPalTokenMargin  = time.Minute
+	PayPalTokenTimeout = 15 * time.Second
+)
+
+// Recherche de concerts à proximité.
+const (
+	DefaultNearbyRadiusKm = 200.0
+	MaxNearbyRadiusKm     = 5000.0
+	MaxNearbyResults      = 200
+)
+
 var (
 	PayPalClientID = getEnvOrDefault("PAYPAL_CLIENT_ID", "AYZTk4mq-RDQ1wx_cV8_OL8x6Z7DLwdIlVgh9VA1-hxIpVl90W0CsIx0LOPnPJhbZUUXtMYGl3005mPi")
 	PayPalSecret   = getEnvOrDefault("PAYPAL_SECRET", "EN_zEbAcKwJluLRQOUJEZbqUmVgRFYxtuy3gD5WoTuLozW8ptEQyp_6uqd3-_6NGQUQxI3h7-88jc-gq")
 	PayPalMode     = getEnvOrDefault("PAYPAL_MODE", "sandbox")
 	PayPalBaseURL  = "https://api-m.sandbox.paypal.com"
+
+	// PaymentProviderKind choisit le prestataire de paiement: "paypal" ou
+	// "fake" (passerelle locale pour développer et tester hors ligne).
+	PaymentProviderKind = getEnvOrDefault("PAYMENT_PROVIDER", "paypal")
+
+	// ArtistSourceKind sélectionne la provenance du catalogue: "remote"
+	// (API Heroku), "snapshot" (dossier JSON local) ou "memory" (jeu de démo).
+	ArtistSourceKind  = getEnvOrDefault("ARTIST_SOURCE", "remote")
+	ArtistSnapshotDir = getEnvOrDefault("ARTIST_SNAPSHOT_DIR", "data/snapshot")
+
+	// RefreshInterval règle l'actualisation automatique du catalogue (0 pour
+	// la désactiver); RefreshJitter décale aléatoirement chaque exécution.
+	RefreshInterval = getEnvDuration("REFRESH_INTERVAL", 30*time.Minute)
+	RefreshJitter   = getEnvDuration("REFRESH_JITTER", 2*time.Minute)
+
+	// HTTPCacheDir conserve les réponses de l'API pour les requêtes
+	// conditionnelles et le démarrage hors ligne ("off" pour désactiver).
+	HTTPCacheDir = getEnvOrDefault("HTTP_CACHE_DIR", "cache/http")
+
+	// GeocodeTTL fixe la durée de validité d'une adresse géocodée; un échec
+	// est mis en quarantaine GeocodeFailureTTL, doublée à chaque nouvel échec.
+	GeocodeTTL           = getEnvDuration("GEOCODE_TTL", 30*24*time.Hour)
+	GeocodeFailureTTL    = getEnvDuration("GEOCODE_FAILURE_TTL", time.Hour)
+	GeocodeMaxFailureTTL = 7 * 24 * time.Hour
+
+	// GeocoderKind choisit le fournisseur de coordonnées: "chain" (gazetteer
+	// hors ligne puis Nominatim), "offline" ou "nominatim".
+	GeocoderKind      = getEnvOrDefault("GEOCODER", "chain")
+	GazetteerPath     = getEnvOrDefault("GAZETTEER_PATH", "data/gazetteer.tsv")
+	NominatimEndpoint = getEnvOrDefault("NOMINATIM_URL", "https://nominatim.openstreetmap.org/search")
+
+	// NominatimEmail est ajouté au User-Agent comme contact, et
+	// NominatimInterval espace les requêtes (une par seconde au maximum).
+	NominatimEmail    = getEnvOrDefault("NOMINATIM_EMAIL", "")
+	NominatimInterval = getEnvDuration("NOMINATIM_INTERVAL", time.Second)
 )
 
 func init() {
@@ -49,3 +129,16 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("%s invalide (%q), valeur par défaut %s utilisée", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
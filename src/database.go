@@ -94,6 +94,8 @@ CREATE TABLE IF NOT EXISTS users (
 		"ALTER TABLE users ADD COLUMN IF NOT EXISTS photo_profil VARCHAR(500) DEFAULT NULL",
 		"ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at DATETIME DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP",
 		"ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) DEFAULT 'user'",
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_token VARCHAR(64) DEFAULT NULL",
+		"ALTER TABLE users ADD UNIQUE INDEX IF NOT EXISTS idx_users_calendar_token (calendar_token)",
 	}
 
 	for _, query := range alterQueries {
@@ -106,5 +108,184 @@ CREATE TABLE IF NOT EXISTS users (
 		_, _ = db.Exec("ALTER TABLE users ADD COLUMN role VARCHAR(20) DEFAULT 'user'")
 	}
 
+	// Même repli pour calendar_token: ADD COLUMN/INDEX IF NOT EXISTS n'existe
+	// que sous MariaDB.
+	err = db.QueryRow("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'calendar_token'").Scan(&columnExists)
+	if err == nil && columnExists == 0 {
+		if _, err := db.Exec("ALTER TABLE users ADD COLUMN calendar_token VARCHAR(64) DEFAULT NULL"); err != nil {
+			return fmt.Errorf("ajout colonne calendar_token: %w", err)
+		}
+	}
+	var indexExists int
+	err = db.QueryRow("SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND INDEX_NAME = 'idx_users_calendar_token'").Scan(&indexExists)
+	if err == nil && indexExists == 0 {
+		if _, err := db.Exec("ALTER TABLE users ADD UNIQUE INDEX idx_users_calendar_token (calendar_token)"); err != nil {
+			return fmt.Errorf("ajout index calendar_token: %w", err)
+		}
+	}
+
+	const catalogChangesTable = `
+CREATE TABLE IF NOT EXISTS catalog_changes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    artist_id INT NOT NULL,
+    artist_name VARCHAR(255) NOT NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    location VARCHAR(255) NOT NULL DEFAULT '',
+    concert_date VARCHAR(32) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_catalog_changes_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(catalogChangesTable); err != nil {
+		return fmt.Errorf("création table catalog_changes: %w", err)
+	}
+
+	const catalogSnapshotTable = `
+CREATE TABLE IF NOT EXISTS catalog_snapshot (
+    id TINYINT PRIMARY KEY,
+    data LONGTEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(catalogSnapshotTable); err != nil {
+		return fmt.Errorf("création table catalog_snapshot: %w", err)
+	}
+
+	const geocodesTable = `
+CREATE TABLE IF NOT EXISTS geocodes (
+    address VARCHAR(255) NOT NULL PRIMARY KEY,
+    latitude DOUBLE NOT NULL DEFAULT 0,
+    longitude DOUBLE NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    error VARCHAR(500) NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME DEFAULT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(geocodesTable); err != nil {
+		return fmt.Errorf("création table geocodes: %w", err)
+	}
+
+	const favoritesTable = `
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id INT NOT NULL,
+    artist_id INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, artist_id),
+    CONSTRAINT fk_favorites_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(favoritesTable); err != nil {
+		return fmt.Errorf("création table favorites: %w", err)
+	}
+
+	const ordersTable = `
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    provider_order_id VARCHAR(64) DEFAULT NULL UNIQUE,
+    artist_id INT NOT NULL,
+    artist_name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    concert_date VARCHAR(32) NOT NULL DEFAULT '',
+    quantity INT NOT NULL,
+    unit_price DECIMAL(10,2) NOT NULL,
+    total DECIMAL(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    capture_id VARCHAR(64) NOT NULL DEFAULT '',
+    error VARCHAR(500) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_orders_user (user_id, created_at),
+    CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(ordersTable); err != nil {
+		return fmt.Errorf("création table orders: %w", err)
+	}
+
+	const ticketsTable = `
+CREATE TABLE IF NOT EXISTS tickets (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    artist_id INT NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    concert_date VARCHAR(32) NOT NULL DEFAULT '',
+    code VARCHAR(32) NOT NULL UNIQUE,
+    status VARCHAR(16) NOT NULL DEFAULT 'valid',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_tickets_user (user_id),
+    CONSTRAINT fk_tickets_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(ticketsTable); err != nil {
+		return fmt.Errorf("création table tickets: %w", err)
+	}
+
+	const ticketPricesTable = `
+CREATE TABLE IF NOT EXISTS ticket_prices (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    artist_id INT NOT NULL DEFAULT 0,
+    location VARCHAR(255) NOT NULL DEFAULT '',
+    concert_date VARCHAR(32) NOT NULL DEFAULT '',
+    price DECIMAL(10,2) NOT NULL,
+    max_per_order INT NOT NULL DEFAULT 0,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_ticket_prices_artist (artist_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(ticketPricesTable); err != nil {
+		return fmt.Errorf("création table ticket_prices: %w", err)
+	}
+
+	const concertCapacityTable = `
+CREATE TABLE IF NOT EXISTS concert_capacity (
+    artist_id INT NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    concert_date VARCHAR(32) NOT NULL,
+    capacity INT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (artist_id, location, concert_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(concertCapacityTable); err != nil {
+		return fmt.Errorf("création table concert_capacity: %w", err)
+	}
+
+	const seatHoldsTable = `
+CREATE TABLE IF NOT EXISTS seat_holds (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT NOT NULL UNIQUE,
+    artist_id INT NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    concert_date VARCHAR(32) NOT NULL,
+    quantity INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_seat_holds_concert (artist_id, location, concert_date),
+    INDEX idx_seat_holds_expiry (status, expires_at),
+    CONSTRAINT fk_seat_holds_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+	if _, err := db.Exec(seatHoldsTable); err != nil {
+		return fmt.Errorf("création table seat_holds: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,244 @@
+package src
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Types MIME des exports cartographiques.
+const (
+	GeoJSONContentType = "application/geo+json"
+	KMLContentType     = "application/vnd.google-earth.kml+xml"
+	GPXContentType     = "application/gpx+xml"
+)
+
+// exportLocation regroupe les concerts d'un artiste en un même lieu localisé.
+type exportLocation struct {
+	Location    string
+	Coordinates Coordinates
+	Dates       []time.Time
+}
+
+// exportLocations regroupe les concerts d'un artiste par lieu, à partir des
+// coordonnées précalculées; les lieux non localisés sont ignorés. Les
+// concerts étant triés par date, les dates de chaque lieu le sont aussi.
+func exportLocations(art Artist) []exportLocation {
+	var locations []exportLocation
+	index := make(map[string]int)
+	for _, c := range art.Concerts {
+		if i, ok := index[c.Raw]; ok {
+			locations[i].Dates = append(locations[i].Dates, c.Date)
+			continue
+		}
+		entry, ok := CachedGeocode(c.Raw)
+		if !ok || entry.Status == GeocodeFailed {
+			continue
+		}
+		index[c.Raw] = len(locations)
+		locations = append(locations, exportLocation{
+			Location:    c.Location(),
+			Coordinates: entry.Coordinates,
+			Dates:       []time.Time{c.Date},
+		})
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].Location < locations[j].Location
+	})
+	return locations
+}
+
+// exportDates formate les dates d'un lieu au format ISO.
+func exportDates(loc exportLocation) []string {
+	dates := make([]string, len(loc.Dates))
+	for i, date := range loc.Dates {
+		dates[i] = date.Format(FilterDateLayout)
+	}
+	return dates
+}
+
+// ArtistGeoJSON construit un point par lieu, avec les dates au format ISO.
+func ArtistGeoJSON(art Artist) GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, loc := range exportLocations(art) {
+		dates := exportDates(loc)
+		collection.Features = append(collection.Features, NewPointFeature(loc.Coordinates, map[string]interface{}{
+			"artist":   art.Name,
+			"location": loc.Location,
+			"dates":    dates,
+		}))
+	}
+	return collection
+}
+
+// Document KML 2.2 minimal: un repère par lieu, la période des concerts en
+// TimeSpan et les dates en ExtendedData.
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string    `xml:"name"`
+	Description string    `xml:"description"`
+	Begin       string    `xml:"TimeSpan>begin"`
+	End         string    `xml:"TimeSpan>end"`
+	Data        []kmlData `xml:"ExtendedData>Data"`
+	Point       kmlPoint  `xml:"Point"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"` // longitude,latitude
+}
+
+// artistKML construit l'export KML des concerts d'un artiste.
+func artistKML(art Artist) kmlDocument {
+	doc := kmlDocument{
+		Namespace: "http://www.opengis.net/kml/2.2",
+		Name:      art.Name + " · concerts",
+	}
+	for _, loc := range exportLocations(art) {
+		dates := exportDates(loc)
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:        loc.Location,
+			Description: fmt.Sprintf("%s · %d concert(s)", art.Name, len(dates)),
+			Begin:       dates[0],
+			End:         dates[len(dates)-1],
+			Data:        []kmlData{{Name: "dates", Value: strings.Join(dates, ", ")}},
+			Point:       kmlPoint{Coordinates: fmt.Sprintf("%.6f,%.6f", loc.Coordinates.Longitude, loc.Coordinates.Latitude)},
+		})
+	}
+	return doc
+}
+
+// Document GPX 1.1 minimal: un point de passage par concert, horodaté.
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Namespace string        `xml:"xmlns,attr"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Name      string        `xml:"metadata>name"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+type gpxWaypoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+	Time      string  `xml:"time"`
+	Name      string  `xml:"name"`
+	Desc      string  `xml:"desc"`
+}
+
+// artistGPX construit l'export GPX des concerts d'un artiste, dans l'ordre
+// chronologique.
+func artistGPX(art Artist) gpxDocument {
+	doc := gpxDocument{
+		Namespace: "http://www.topografix.com/GPX/1/1",
+		Version:   "1.1",
+		Creator:   "Groupie Tracker",
+		Name:      art.Name + " · concerts",
+	}
+	for _, loc := range exportLocations(art) {
+		for _, date := range loc.Dates {
+			doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+				Latitude:  loc.Coordinates.Latitude,
+				Longitude: loc.Coordinates.Longitude,
+				Time:      date.UTC().Format(time.RFC3339),
+				Name:      loc.Location,
+				Desc:      art.Name,
+			})
+		}
+	}
+	sort.SliceStable(doc.Waypoints, func(i, j int) bool {
+		return doc.Waypoints[i].Time < doc.Waypoints[j].Time
+	})
+	return doc
+}
+
+// exportFilename construit un nom de fichier à partir du nom de l'artiste
+// ("Pink Floyd" -> "pink-floyd-concerts.kml").
+func exportFilename(art Artist, ext string) string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '-'
+	}, FoldText(art.Name))
+	slug = strings.Trim(slug, "-")
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	if slug == "" {
+		slug = "artiste"
+	}
+	return slug + "-concerts." + ext
+}
+
+func setDownloadHeaders(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+// HandleArtistGeoJSON exporte les lieux de concert d'un artiste en GeoJSON.
+func (s *Server) HandleArtistGeoJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	art, ok := s.artistFromPath(w, r)
+	if !ok {
+		return
+	}
+	setDownloadHeaders(w, GeoJSONContentType, exportFilename(art, "geojson"))
+	json.NewEncoder(w).Encode(ArtistGeoJSON(art))
+}
+
+// HandleArtistKML exporte les lieux de concert d'un artiste en KML.
+func (s *Server) HandleArtistKML(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	art, ok := s.artistFromPath(w, r)
+	if !ok {
+		return
+	}
+	setDownloadHeaders(w, KMLContentType, exportFilename(art, "kml"))
+	writeXML(w, artistKML(art))
+}
+
+// HandleArtistGPX exporte les concerts d'un artiste en GPX.
+func (s *Server) HandleArtistGPX(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	art, ok := s.artistFromPath(w, r)
+	if !ok {
+		return
+	}
+	setDownloadHeaders(w, GPXContentType, exportFilename(art, "gpx"))
+	writeXML(w, artistGPX(art))
+}
+
+func writeXML(w http.ResponseWriter, doc interface{}) {
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		log.Printf("Erreur encodage XML: %v", err)
+	}
+}
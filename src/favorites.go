@@ -0,0 +1,155 @@
+package src
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// IsFavorite indique si l'artiste fait partie des favoris de l'utilisateur.
+func IsFavorite(db *sql.DB, userID, artistID int) (bool, error) {
+	var count int
+	const query = `SELECT COUNT(*) FROM favorites WHERE user_id = ? AND artist_id = ?`
+	if err := db.QueryRow(query, userID, artistID).Scan(&count); err != nil {
+		return false, fmt.Errorf("lecture favori: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ToggleFavorite ajoute ou retire un artiste des favoris et renvoie le nouvel
+// état.
+func ToggleFavorite(db *sql.DB, userID, artistID int) (bool, error) {
+	res, err := db.Exec(`DELETE FROM favorites WHERE user_id = ? AND artist_id = ?`, userID, artistID)
+	if err != nil {
+		return false, fmt.Errorf("suppression favori: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return false, nil
+	}
+	if _, err := db.Exec(`INSERT IGNORE INTO favorites (user_id, artist_id) VALUES (?, ?)`, userID, artistID); err != nil {
+		return false, fmt.Errorf("ajout favori: %w", err)
+	}
+	return true, nil
+}
+
+// ListFavoriteIDs renvoie les identifiants des artistes favoris, du plus
+// récent au plus ancien.
+func ListFavoriteIDs(db *sql.DB, userID int) ([]int, error) {
+	rows, err := db.Query(`SELECT artist_id FROM favorites WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lecture favoris: %w", err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("lecture favoris: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CalendarToken renvoie le jeton du flux iCalendar personnel de
+// l'utilisateur, en le créant au premier appel.
+func CalendarToken(db *sql.DB, userID int) (string, error) {
+	var token sql.NullString
+	if err := db.QueryRow(`SELECT calendar_token FROM users WHERE id = ?`, userID).Scan(&token); err != nil {
+		return "", fmt.Errorf("lecture jeton calendrier: %w", err)
+	}
+	if token.Valid && token.String != "" {
+		return token.String, nil
+	}
+	return ResetCalendarToken(db, userID)
+}
+
+// ResetCalendarToken génère un nouveau jeton, ce qui révoque l'ancienne URL
+// d'abonnement.
+func ResetCalendarToken(db *sql.DB, userID int) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("génération jeton calendrier: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if _, err := db.Exec(`UPDATE users SET calendar_token = ? WHERE id = ?`, token, userID); err != nil {
+		return "", fmt.Errorf("enregistrement jeton calendrier: %w", err)
+	}
+	return token, nil
+}
+
+// UserIDByCalendarToken retrouve l'utilisateur propriétaire d'un jeton.
+func UserIDByCalendarToken(db *sql.DB, token string) (int, error) {
+	var id int
+	err := db.QueryRow(`SELECT id FROM users WHERE calendar_token = ? LIMIT 1`, token).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("jeton calendrier inconnu")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("lecture jeton calendrier: %w", err)
+	}
+	return id, nil
+}
+
+// sessionUserID renvoie l'identifiant de l'utilisateur connecté.
+func sessionUserID(r *http.Request) (int, bool) {
+	session, err := GetSession(r)
+	if err != nil {
+		return 0, false
+	}
+	userID, ok := session.Values["user_id"].(int)
+	return userID, ok
+}
+
+// HandleToggleFavorite ajoute ou retire l'artiste des favoris puis revient
+// sur sa page.
+func (s *Server) HandleToggleFavorite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := sessionUserID(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	artistID, err := strconv.Atoi(r.FormValue("artist_id"))
+	if err != nil || artistID <= 0 {
+		http.Error(w, "Identifiant invalide", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.FindArtist(artistID); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := ToggleFavorite(DB, userID, artistID); err != nil {
+		log.Printf("Erreur favori: %v", err)
+		http.Error(w, "Erreur lors de la mise à jour des favoris", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/artist?id="+strconv.Itoa(artistID), http.StatusSeeOther)
+}
+
+// HandleResetCalendarToken révoque l'URL du flux iCalendar personnel.
+func (s *Server) HandleResetCalendarToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := sessionUserID(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if _, err := ResetCalendarToken(DB, userID); err != nil {
+		log.Printf("Erreur jeton calendrier: %v", err)
+		http.Error(w, "Erreur lors du renouvellement du lien", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
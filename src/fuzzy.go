@@ -0,0 +1,128 @@
+package src
+
+import (
+	"sort"
+	"strings"
+)
+
+// fuzzyFactor pondère les correspondances approximatives, toujours moins
+// pertinentes qu'une correspondance exacte ou par préfixe.
+const fuzzyFactor = 0.3
+
+// DamerauLevenshtein calcule la distance d'édition entre deux mots en
+// comptant insertions, suppressions, substitutions et transpositions de
+// lettres adjacentes (variante "optimal string alignment").
+func DamerauLevenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+// maxEdits renvoie la distance tolérée selon la longueur du mot: aucune
+// faute sous 4 lettres, une jusqu'à 5, deux au-delà.
+func maxEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// trigrams découpe un mot en trigrammes, bornes comprises ("  q", " qu"...).
+func trigrams(word string) []string {
+	runes := []rune("  " + word + " ")
+	result := make([]string, 0, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		result = append(result, string(runes[i:i+3]))
+	}
+	return result
+}
+
+// fuzzyMatch est un terme de l'index proche d'un mot de la requête.
+type fuzzyMatch struct {
+	term     string
+	distance int
+}
+
+// fuzzyTerms renvoie les termes approchants d'un mot parmi les noms, membres
+// et villes. Les candidats sont présélectionnés par trigrammes communs avant
+// le calcul de distance.
+func (idx *SearchIndex) fuzzyTerms(token string) []fuzzyMatch {
+	limit := maxEdits(token)
+	if limit == 0 {
+		return nil
+	}
+	shared := make(map[string]int)
+	for _, gram := range trigrams(token) {
+		for _, term := range idx.trigrams[gram] {
+			shared[term]++
+		}
+	}
+	var matches []fuzzyMatch
+	for term := range shared {
+		if term == token {
+			continue
+		}
+		if diff := len(term) - len(token); diff > limit || -diff > limit {
+			continue
+		}
+		if d := DamerauLevenshtein(token, term); d <= limit {
+			matches = append(matches, fuzzyMatch{term: term, distance: d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		if len(idx.postings[matches[i].term]) != len(idx.postings[matches[j].term]) {
+			return len(idx.postings[matches[i].term]) > len(idx.postings[matches[j].term])
+		}
+		return matches[i].term < matches[j].term
+	})
+	return matches
+}
+
+// Suggest propose une correction de la requête ("queeen" -> "queen") en
+// remplaçant chaque mot inconnu par le terme le plus proche. Renvoie ""
+// si aucune correction n'est possible ou nécessaire.
+func (idx *SearchIndex) Suggest(query string) string {
+	tokens := Tokenize(query)
+	changed := false
+	for i, token := range tokens {
+		if len(idx.prefixTerms(token)) > 0 {
+			continue
+		}
+		matches := idx.fuzzyTerms(token)
+		if len(matches) == 0 {
+			return ""
+		}
+		tokens[i] = matches[0].term
+		changed = true
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(tokens, " ")
+}
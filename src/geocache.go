@@ -0,0 +1,266 @@
+package src
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuts d'une entrée du cache de géocodage.
+const (
+	GeocodeOK     = "ok"
+	GeocodeFailed = "failed"
+	GeocodeManual = "manual" // coordonnées saisies par un administrateur, sans expiration
+)
+
+// GeocodeEntry est une adresse géocodée (ou en échec) conservée en cache.
+type GeocodeEntry struct {
+	Address     string
+	Coordinates Coordinates
+	Status      string
+	Error       string
+	Attempts    int
+	UpdatedAt   time.Time
+	ExpiresAt   time.Time // zéro: pas d'expiration
+}
+
+// Expired indique si l'entrée doit être recalculée.
+func (e GeocodeEntry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// failureTTL double la durée de mise en quarantaine d'une adresse à chaque
+// échec consécutif, dans la limite de GeocodeMaxFailureTTL.
+func failureTTL(attempts int) time.Duration {
+	ttl := GeocodeFailureTTL
+	for i := 1; i < attempts && ttl < GeocodeMaxFailureTTL; i++ {
+		ttl *= 2
+	}
+	if ttl > GeocodeMaxFailureTTL {
+		ttl = GeocodeMaxFailureTTL
+	}
+	return ttl
+}
+
+// LoadGeocodeCache charge la table geocodes dans le cache mémoire.
+func LoadGeocodeCache(db *sql.DB) error {
+	entries, err := ListGeocodes(db)
+	if err != nil {
+		return err
+	}
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	for _, entry := range entries {
+		geocodeCache[entry.Address] = entry
+	}
+	log.Printf("%d adresse(s) géocodée(s) chargée(s) depuis la base", len(entries))
+	return nil
+}
+
+// storeGeocode met à jour le cache mémoire et, si la base est disponible,
+// la table geocodes.
+func storeGeocode(entry GeocodeEntry) {
+	cacheMutex.Lock()
+	geocodeCache[entry.Address] = entry
+	cacheMutex.Unlock()
+	if DB == nil {
+		return
+	}
+	if err := SaveGeocode(DB, entry); err != nil {
+		log.Printf("Cache geocoding non persisté pour %s: %v", entry.Address, err)
+	}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// SaveGeocode insère ou remplace une entrée de la table geocodes.
+func SaveGeocode(db *sql.DB, e GeocodeEntry) error {
+	const query = `INSERT INTO geocodes (address, latitude, longitude, status, error, attempts, updated_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE latitude = VALUES(latitude), longitude = VALUES(longitude), status = VALUES(status),
+error = VALUES(error), attempts = VALUES(attempts), updated_at = VALUES(updated_at), expires_at = VALUES(expires_at)`
+	message := e.Error
+	if len(message) > 500 {
+		message = message[:500]
+	}
+	_, err := db.Exec(query, e.Address, e.Coordinates.Latitude, e.Coordinates.Longitude, e.Status, message, e.Attempts, e.UpdatedAt, nullTime(e.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("enregistrement geocode: %w", err)
+	}
+	return nil
+}
+
+// ListGeocodes renvoie toutes les entrées, par adresse.
+func ListGeocodes(db *sql.DB) ([]GeocodeEntry, error) {
+	rows, err := db.Query(`SELECT address, latitude, longitude, status, error, attempts, updated_at, expires_at FROM geocodes ORDER BY address`)
+	if err != nil {
+		return nil, fmt.Errorf("liste geocodes: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []GeocodeEntry
+	for rows.Next() {
+		var e GeocodeEntry
+		var expires sql.NullTime
+		if err := rows.Scan(&e.Address, &e.Coordinates.Latitude, &e.Coordinates.Longitude, &e.Status, &e.Error, &e.Attempts, &e.UpdatedAt, &expires); err != nil {
+			return nil, fmt.Errorf("scan geocode: %w", err)
+		}
+		if expires.Valid {
+			e.ExpiresAt = expires.Time
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteGeocode supprime une adresse de la base et du cache mémoire.
+func DeleteGeocode(db *sql.DB, address string) error {
+	if _, err := db.Exec(`DELETE FROM geocodes WHERE address = ?`, address); err != nil {
+		return fmt.Errorf("suppression geocode: %w", err)
+	}
+	cacheMutex.Lock()
+	delete(geocodeCache, address)
+	cacheMutex.Unlock()
+	return nil
+}
+
+// PurgeGeocodes supprime les entrées en échec ("failed"), expirées
+// ("expired") ou toutes ("all").
+func PurgeGeocodes(db *sql.DB, scope string) (int64, error) {
+	var res sql.Result
+	var err error
+	switch scope {
+	case "failed":
+		res, err = db.Exec(`DELETE FROM geocodes WHERE status = ?`, GeocodeFailed)
+	case "expired":
+		res, err = db.Exec(`DELETE FROM geocodes WHERE expires_at IS NOT NULL AND expires_at < ?`, time.Now())
+	case "all":
+		res, err = db.Exec(`DELETE FROM geocodes`)
+	default:
+		return 0, fmt.Errorf("purge inconnue: %s", scope)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("purge geocodes: %w", err)
+	}
+
+	now := time.Now()
+	cacheMutex.Lock()
+	for address, entry := range geocodeCache {
+		if scope == "all" || (scope == "failed" && entry.Status == GeocodeFailed) || (scope == "expired" && entry.Expired(now)) {
+			delete(geocodeCache, address)
+		}
+	}
+	cacheMutex.Unlock()
+	return res.RowsAffected()
+}
+
+// HandleAdminGeocodes affiche le cache de géocodage (admin seulement).
+func (s *Server) HandleAdminGeocodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := ListGeocodes(DB)
+	if err != nil {
+		log.Printf("Erreur lecture geocodes: %v", err)
+		http.Error(w, "Erreur lors de la récupération du cache de géocodage", http.StatusInternalServerError)
+		return
+	}
+	data := AdminGeocodesPageData{
+		Entries: entries,
+		Now:     time.Now(),
+		Message: r.URL.Query().Get("message"),
+		User:    currentUserProfile(r),
+	}
+	for _, e := range entries {
+		if e.Status == GeocodeFailed {
+			data.Failed++
+		}
+	}
+	s.Render(w, "admin-geocodes.html", data)
+}
+
+// HandleAdminUpdateGeocode corrige manuellement les coordonnées d'une adresse.
+func (s *Server) HandleAdminUpdateGeocode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+	address := strings.TrimSpace(r.FormValue("address"))
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(r.FormValue("latitude")), 64)
+	lon, errLon := strconv.ParseFloat(strings.TrimSpace(r.FormValue("longitude")), 64)
+	if address == "" || errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		http.Error(w, "Adresse ou coordonnées invalides", http.StatusBadRequest)
+		return
+	}
+	entry := GeocodeEntry{
+		Address:     address,
+		Coordinates: Coordinates{Latitude: lat, Longitude: lon},
+		Status:      GeocodeManual,
+		UpdatedAt:   time.Now(),
+	}
+	if err := SaveGeocode(DB, entry); err != nil {
+		log.Printf("Erreur correction geocode: %v", err)
+		http.Error(w, "Erreur lors de la mise à jour des coordonnées", http.StatusInternalServerError)
+		return
+	}
+	cacheMutex.Lock()
+	geocodeCache[address] = entry
+	cacheMutex.Unlock()
+	http.Redirect(w, r, "/admin/geocodes", http.StatusSeeOther)
+}
+
+// HandleAdminDeleteGeocode supprime une adresse pour forcer un nouveau
+// géocodage.
+func (s *Server) HandleAdminDeleteGeocode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+	address := strings.TrimSpace(r.FormValue("address"))
+	if address == "" {
+		http.Error(w, "Adresse manquante", http.StatusBadRequest)
+		return
+	}
+	if err := DeleteGeocode(DB, address); err != nil {
+		log.Printf("Erreur suppression geocode: %v", err)
+		http.Error(w, "Erreur lors de la suppression", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/geocodes", http.StatusSeeOther)
+}
+
+// HandleAdminPurgeGeocodes vide tout ou partie du cache de géocodage.
+func (s *Server) HandleAdminPurgeGeocodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+	count, err := PurgeGeocodes(DB, r.FormValue("scope"))
+	if err != nil {
+		log.Printf("Erreur purge geocodes: %v", err)
+		http.Error(w, "Erreur lors de la purge", http.StatusBadRequest)
+		return
+	}
+	message := url.Values{"message": {fmt.Sprintf("%d entrée(s) supprimée(s)", count)}}
+	http.Redirect(w, r, "/admin/geocodes?"+message.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,193 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrAddressNotFound signale qu'un fournisseur ne connaît pas l'adresse
+// demandée (par opposition à une panne du fournisseur).
+var ErrAddressNotFound = errors.New("adresse non trouvée")
+
+// Geocoder convertit une adresse (clé de lieu de l'API ou texte libre) en
+// coordonnées géographiques.
+type Geocoder interface {
+	Name() string
+	Geocode(ctx context.Context, address string) (Coordinates, error)
+}
+
+// geocoder est le fournisseur utilisé par GeocodeLocation, choisi par
+// GeocoderKind au démarrage.
+var geocoder = newDefaultGeocoder()
+
+// NewGeocoder construit le fournisseur de géocodage demandé: "chain"
+// (gazetteer hors ligne puis Nominatim), "offline" ou "nominatim".
+func NewGeocoder(kind string) (Geocoder, error) {
+	switch strings.ToLower(kind) {
+	case "", "chain":
+		gazetteer, err := LoadGazetteer(GazetteerPath)
+		if err != nil {
+			return nil, err
+		}
+		return ChainGeocoder{gazetteer, NewNominatimGeocoder()}, nil
+	case "offline":
+		return LoadGazetteer(GazetteerPath)
+	case "nominatim":
+		return NewNominatimGeocoder(), nil
+	default:
+		return nil, fmt.Errorf("géocodeur inconnu: %s", kind)
+	}
+}
+
+func newDefaultGeocoder() Geocoder {
+	g, err := NewGeocoder(GeocoderKind)
+	if err != nil {
+		log.Printf("Géocodeur %q indisponible, repli sur Nominatim: %v", GeocoderKind, err)
+		return NewNominatimGeocoder()
+	}
+	return g
+}
+
+// ChainGeocoder interroge ses fournisseurs dans l'ordre et renvoie la
+// première réponse trouvée.
+type ChainGeocoder []Geocoder
+
+func (chain ChainGeocoder) Name() string {
+	names := make([]string, len(chain))
+	for i, g := range chain {
+		names[i] = g.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (chain ChainGeocoder) Geocode(ctx context.Context, address string) (Coordinates, error) {
+	err := fmt.Errorf("%w: %s", ErrAddressNotFound, address)
+	for _, g := range chain {
+		coords, gErr := g.Geocode(ctx, address)
+		if gErr == nil {
+			return coords, nil
+		}
+		// Une panne d'un fournisseur prime sur un simple "non trouvé"
+		if !errors.Is(gErr, ErrAddressNotFound) || errors.Is(err, ErrAddressNotFound) {
+			err = fmt.Errorf("%s: %w", g.Name(), gErr)
+		}
+	}
+	return Coordinates{}, err
+}
+
+// GazetteerGeocoder résout les lieux hors ligne à partir d'un fichier TSV
+// embarqué (data/gazetteer.tsv): nom, code pays ISO, type, latitude,
+// longitude et noms alternatifs séparés par des virgules.
+type GazetteerGeocoder struct {
+	byKey  map[string]Coordinates   // "nom|PAYS"
+	byName map[string][]Coordinates // nom seul, pour le texte libre
+}
+
+// LoadGazetteer lit le gazetteer depuis path.
+func LoadGazetteer(path string) (*GazetteerGeocoder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ouverture gazetteer: %w", err)
+	}
+	defer file.Close()
+
+	g := &GazetteerGeocoder{
+		byKey:  make(map[string]Coordinates),
+		byName: make(map[string][]Coordinates),
+	}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("gazetteer %s ligne %d: 5 colonnes attendues", path, line)
+		}
+		lat, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer %s ligne %d: latitude: %w", path, line, err)
+		}
+		lon, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer %s ligne %d: longitude: %w", path, line, err)
+		}
+		coords := Coordinates{Latitude: lat, Longitude: lon}
+		names := []string{fields[0]}
+		if len(fields) > 5 && fields[5] != "" {
+			names = append(names, strings.Split(fields[5], ",")...)
+		}
+		for _, name := range names {
+			g.add(name, strings.ToUpper(fields[1]), coords)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("lecture gazetteer: %w", err)
+	}
+	log.Printf("Gazetteer chargé: %d lieu(x) depuis %s", len(g.byKey), path)
+	return g, nil
+}
+
+func (g *GazetteerGeocoder) add(name, country string, coords Coordinates) {
+	name = gazetteerKey(name)
+	if name == "" {
+		return
+	}
+	g.byKey[name+"|"+country] = coords
+	for _, existing := range g.byName[name] {
+		if existing == coords {
+			return
+		}
+	}
+	g.byName[name] = append(g.byName[name], coords)
+}
+
+func (g *GazetteerGeocoder) Name() string {
+	return "offline"
+}
+
+// Geocode accepte une clé de l'API ("los_angeles-usa") ou un texte libre
+// ("Paris, France"); un nom seul n'est résolu que s'il est sans ambiguïté.
+func (g *GazetteerGeocoder) Geocode(ctx context.Context, address string) (Coordinates, error) {
+	if city, region, country, err := ParseLocation(address); err == nil {
+		name := city
+		if name == "" {
+			name = region
+		}
+		if coords, ok := g.byKey[gazetteerKey(name)+"|"+country]; ok {
+			return coords, nil
+		}
+		return Coordinates{}, fmt.Errorf("%w: %s", ErrAddressNotFound, address)
+	}
+
+	parts := strings.Split(address, ",")
+	name := gazetteerKey(parts[0])
+	if len(parts) > 1 {
+		country := strings.ReplaceAll(gazetteerKey(parts[len(parts)-1]), " ", "_")
+		if code, ok := countryCodes[country]; ok {
+			if coords, ok := g.byKey[name+"|"+code]; ok {
+				return coords, nil
+			}
+		}
+	}
+	if matches := g.byName[name]; len(matches) == 1 {
+		return matches[0], nil
+	}
+	return Coordinates{}, fmt.Errorf("%w: %s", ErrAddressNotFound, address)
+}
+
+// gazetteerKey normalise un nom de lieu: sans accents, en minuscules, les
+// séparateurs "_" et "-" remplacés par des espaces.
+func gazetteerKey(name string) string {
+	name = strings.NewReplacer("_", " ", "-", " ", ".", " ").Replace(FoldText(name))
+	return strings.Join(strings.Fields(name), " ")
+}
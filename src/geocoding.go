@@ -1,12 +1,14 @@
 package src
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,55 +25,197 @@ type LocationWithCoords struct {
 	Location    string     `json:"location"`
 	Coordinates Coordinates `json:"coordinates"`
 	Dates       []string   `json:"dates"`
+	Status      string     `json:"status"`
 }
 
+// Statut de localisation d'un lieu sur la carte d'un artiste
+const (
+	LocationResolved   = "resolved"
+	LocationPending    = "pending"    // pas encore traité par le pré-géocodage
+	LocationUnresolved = "unresolved" // géocodage en échec
+)
+
 var (
-	// Cache pour stocker les coordonnées géocodées
-	geocodeCache = make(map[string]Coordinates)
+	// Cache pour stocker les coordonnées géocodées (et les échecs récents),
+	// alimenté au démarrage par la table geocodes
+	geocodeCache = make(map[string]GeocodeEntry)
 	cacheMutex   sync.RWMutex
+
+	// Géocodages en cours, partagés entre les appelants demandant la même
+	// adresse au même moment
+	geocodeInflight = make(map[string]*geocodeCall)
+	inflightMutex   sync.Mutex
+
+	// Quota partagé par tous les appels à Nominatim (1 requête/s par défaut)
+	nominatimLimiter = NewTokenBucket(NominatimInterval, 1)
 )
 
+// geocodeCall représente un géocodage en cours pour une adresse
+type geocodeCall struct {
+	done   chan struct{}
+	coords Coordinates
+	err    error
+}
+
 // GeocodeLocation convertit une adresse en coordonnées géographiques
-// Utilise Nominatim (OpenStreetMap) qui est gratuit et ne nécessite pas de clé API
+// via le géocodeur configuré (gazetteer hors ligne, puis Nominatim)
 func GeocodeLocation(address string) (Coordinates, error) {
-	// Vérifier le cache d'abord
+	// Vérifier le cache d'abord: une adresse en échec n'est pas retentée
+	// avant l'expiration de sa quarantaine
 	cacheMutex.RLock()
-	if coords, exists := geocodeCache[address]; exists {
-		cacheMutex.RUnlock()
-		return coords, nil
+	entry, exists := geocodeCache[address]
+	cacheMutex.RUnlock()
+	if exists && !entry.Expired(time.Now()) {
+		if entry.Status == GeocodeFailed {
+			return Coordinates{}, fmt.Errorf("geocoding en échec pour %s jusqu'au %s: %s", address, entry.ExpiresAt.Format("02/01/2006 15:04"), entry.Error)
+		}
+		return entry.Coordinates, nil
 	}
+
+	// Une seule requête par adresse: les appelants suivants attendent
+	// le résultat de la première
+	inflightMutex.Lock()
+	if call, ok := geocodeInflight[address]; ok {
+		inflightMutex.Unlock()
+		<-call.done
+		return call.coords, call.err
+	}
+	call := &geocodeCall{done: make(chan struct{})}
+	geocodeInflight[address] = call
+	inflightMutex.Unlock()
+
+	call.coords, call.err = resolveGeocode(address, entry, exists)
+
+	inflightMutex.Lock()
+	delete(geocodeInflight, address)
+	inflightMutex.Unlock()
+	close(call.done)
+	return call.coords, call.err
+}
+
+// LookupLocation géocode une saisie libre d'utilisateur sans rien
+// enregistrer: le cache n'est que consulté, pour que les recherches
+// ponctuelles n'encombrent ni les géocodages ni la quarantaine.
+func LookupLocation(ctx context.Context, address string) (Coordinates, error) {
+	cacheMutex.RLock()
+	entry, exists := geocodeCache[address]
 	cacheMutex.RUnlock()
+	if exists && entry.Status == GeocodeOK && !entry.Expired(time.Now()) {
+		return entry.Coordinates, nil
+	}
+	return geocoder.Geocode(ctx, address)
+}
+
+// resolveGeocode interroge le géocodeur et enregistre le résultat (ou
+// l'échec) dans le cache; previous est l'entrée expirée éventuelle
+func resolveGeocode(address string, previous GeocodeEntry, exists bool) (Coordinates, error) {
+	coords, err := geocoder.Geocode(context.Background(), address)
+	if err != nil {
+		attempts := 1
+		if exists && previous.Status == GeocodeFailed {
+			attempts = previous.Attempts + 1
+		}
+		now := time.Now()
+		storeGeocode(GeocodeEntry{
+			Address:   address,
+			Status:    GeocodeFailed,
+			Error:     err.Error(),
+			Attempts:  attempts,
+			UpdatedAt: now,
+			ExpiresAt: now.Add(failureTTL(attempts)),
+		})
+		return Coordinates{}, err
+	}
+
+	now := time.Now()
+	storeGeocode(GeocodeEntry{
+		Address:     address,
+		Coordinates: coords,
+		Status:      GeocodeOK,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(GeocodeTTL),
+	})
+	log.Printf("Geocodé (%s): %s -> (%.6f, %.6f)", geocoder.Name(), address, coords.Latitude, coords.Longitude)
+	return coords, nil
+}
+
+// NominatimGeocoder utilise Nominatim (OpenStreetMap) qui est gratuit et
+// ne nécessite pas de clé API. Sa politique d'utilisation impose au plus
+// une requête par seconde et un contact identifiable (Email).
+type NominatimGeocoder struct {
+	BaseURL   string
+	UserAgent string
+	Email     string
+	client    *http.Client
+}
+
+// NewNominatimGeocoder construit un géocodeur sur l'instance publique de Nominatim
+func NewNominatimGeocoder() *NominatimGeocoder {
+	return &NominatimGeocoder{
+		BaseURL:   NominatimEndpoint,
+		UserAgent: "GroupieTracker/1.0",
+		Email:     NominatimEmail,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (g *NominatimGeocoder) Name() string {
+	return "nominatim"
+}
+
+// Geocode interroge l'API Nominatim pour une adresse
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address string) (Coordinates, error) {
 
 	// Nettoyer l'adresse (remplacer _ par des espaces, formater)
 	cleanAddr := CleanAddressForGeocoding(address)
 	
 	// Construire l'URL de l'API Nominatim
-	baseURL := "https://nominatim.openstreetmap.org/search"
 	params := url.Values{}
 	params.Set("q", cleanAddr)
 	params.Set("format", "json")
 	params.Set("limit", "1")
+	if g.Email != "" {
+		params.Set("email", g.Email)
+	}
 	
-	reqURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+	reqURL := fmt.Sprintf("%s?%s", g.BaseURL, params.Encode())
 	
 	// Créer la requête HTTP
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return Coordinates{}, fmt.Errorf("erreur création requête: %v", err)
 	}
 	
-	// Ajouter un User-Agent (requis par Nominatim)
-	req.Header.Set("User-Agent", "GroupieTracker/1.0")
-	
-	// Client HTTP avec timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	// Ajouter un User-Agent identifiant l'application (requis par Nominatim)
+	userAgent := g.UserAgent
+	if g.Email != "" {
+		userAgent += " (" + g.Email + ")"
 	}
+	req.Header.Set("User-Agent", userAgent)
 	
-	// Effectuer la requête
-	resp, err := client.Do(req)
-	if err != nil {
-		return Coordinates{}, fmt.Errorf("erreur requête geocoding: %v", err)
+	// Effectuer la requête au rythme autorisé par Nominatim: une réponse
+	// 429/503 suspend toutes les requêtes pendant le délai Retry-After
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		if err := nominatimLimiter.Wait(ctx); err != nil {
+			return Coordinates{}, fmt.Errorf("attente quota Nominatim: %w", err)
+		}
+		resp, err = g.client.Do(req)
+		if err != nil {
+			return Coordinates{}, fmt.Errorf("erreur requête geocoding: %v", err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			break
+		}
+		resp.Body.Close()
+		delay := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now(), NominatimBackoff)
+		nominatimLimiter.Pause(delay)
+		log.Printf("Nominatim limite les requêtes (HTTP %d), pause de %s", resp.StatusCode, delay)
+		if attempt >= 2 || delay > NominatimMaxRetryWait {
+			return Coordinates{}, fmt.Errorf("quota Nominatim dépassé (HTTP %d), nouvel essai dans %s", resp.StatusCode, delay)
+		}
 	}
 	defer resp.Body.Close()
 	
@@ -100,7 +244,7 @@ func GeocodeLocation(address string) (Coordinates, error) {
 	// Vérifier si des résultats ont été trouvés
 	if len(results) == 0 {
 		log.Printf("Aucun résultat de geocoding pour: %s", address)
-		return Coordinates{}, fmt.Errorf("adresse non trouvée: %s", address)
+		return Coordinates{}, fmt.Errorf("%w: %s", ErrAddressNotFound, address)
 	}
 	
 	// Convertir les chaînes en float64
@@ -112,12 +256,6 @@ func GeocodeLocation(address string) (Coordinates, error) {
 		return Coordinates{}, fmt.Errorf("erreur parsing longitude: %v", err)
 	}
 	
-	// Stocker dans le cache
-	cacheMutex.Lock()
-	geocodeCache[address] = coords
-	cacheMutex.Unlock()
-	
-	log.Printf("Geocodé: %s -> (%.6f, %.6f)", address, coords.Latitude, coords.Longitude)
 	return coords, nil
 }
 
@@ -137,53 +275,47 @@ func CleanAddressForGeocoding(address string) string {
 	return cleaned
 }
 
-// GeocodeLocations geocode plusieurs adresses en parallèle
-func GeocodeLocations(locations []string, relations map[string][]string) []LocationWithCoords {
-	if len(relations) == 0 {
+// CachedGeocode renvoie l'entrée du cache pour une adresse, sans appel réseau
+func CachedGeocode(address string) (GeocodeEntry, bool) {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	entry, ok := geocodeCache[address]
+	return entry, ok
+}
+
+// LocationsWithCoords associe aux lieux des concerts les coordonnées
+// précalculées par le pré-géocodage, sans interroger le géocodeur: un lieu
+// absent du cache est "pending", un lieu en échec "unresolved"
+func LocationsWithCoords(concerts []Concert) []LocationWithCoords {
+	if len(concerts) == 0 {
 		return nil
 	}
-	
-	resultsChan := make(chan LocationWithCoords, len(relations))
-	var wg sync.WaitGroup
-	
-	// Geocoder en parallèle (avec limite de concurrence)
-	semaphore := make(chan struct{}, 5) // Maximum 5 requêtes simultanées
-	
-	for location, dates := range relations {
-		wg.Add(1)
-		go func(loc string, dts []string) {
-			defer wg.Done()
-			
-			// Acquérir un slot
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-			
-			coords, err := GeocodeLocation(loc)
-			if err != nil {
-				log.Printf("Erreur geocoding pour %s: %v", loc, err)
-				// Continuer même en cas d'erreur (coordonnées à 0,0)
-			}
-			
-			resultsChan <- LocationWithCoords{
-				Location:    FormatLocation(loc),
-				Coordinates: coords,
-				Dates:       CleanDates(dts),
-			}
-		}(location, dates)
+
+	// Regrouper les dates par lieu
+	relations := make(map[string][]string)
+	for _, c := range concerts {
+		relations[c.Raw] = append(relations[c.Raw], c.Date.Format(APIDateLayout))
 	}
-	
-	// Fermer le channel une fois toutes les goroutines terminées
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
-	
-	// Collecter les résultats
+
 	results := make([]LocationWithCoords, 0, len(relations))
-	for res := range resultsChan {
+	for location, dates := range relations {
+		res := LocationWithCoords{
+			Location: FormatLocation(location),
+			Dates:    dates,
+			Status:   LocationPending,
+		}
+		if entry, ok := CachedGeocode(location); ok {
+			if entry.Status == GeocodeFailed {
+				res.Status = LocationUnresolved
+			} else {
+				res.Status = LocationResolved
+				res.Coordinates = entry.Coordinates
+			}
+		}
 		results = append(results, res)
 	}
-	
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Location < results[j].Location
+	})
 	return results
 }
-
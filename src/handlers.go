@@ -3,6 +3,7 @@ package src
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,34 +16,48 @@ import (
 )
 
 func (s *Server) HandleIndex(w http.ResponseWriter, r *http.Request) {
-	var userProfile *UserProfile
-	if IsAuthenticated(r) {
-		session, _ := GetSession(r)
-		if userID, ok := session.Values["user_id"].(int); ok {
-			user, err := GetUserByID(DB, userID)
-			if err == nil {
-				userProfile = &UserProfile{
-					ID:          user.ID,
-					Username:    user.Username,
-					Email:       user.Email,
-					Pseudo:      getStringValue(user.Pseudo),
-					Bio:         getStringValue(user.Bio),
-					PhotoProfil: getStringValue(user.PhotoProfil),
-					Role:        user.Role,
-				}
-			}
-		}
-	}
+	userProfile := currentUserProfile(r)
 
 	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	filters := ParseArtistFilters(r.URL.Query())
 	artists := s.ListArtists()
-	filtered := FilterArtists(artists, query)
+	results := s.SearchArtists(query)
+	matched := make([]Artist, len(results))
+	exact := false
+	for i, res := range results {
+		matched[i] = res.Artist
+		exact = exact || !res.Fuzzy
+	}
+	filtered := ApplyFilters(matched, filters)
+
+	params := r.URL.Query()
+	sortKey, order := NormalizeSort(params.Get("sort"), params.Get("order"))
+	SortArtists(filtered, sortKey, order, time.Now())
+
+	perPage := parsePositiveInt(params.Get("per_page"), ArtistsPageSize)
+	if perPage > MaxArtistsPageSize {
+		perPage = MaxArtistsPageSize
+	}
+	pagination := Paginate(len(filtered), parsePositiveInt(params.Get("page"), 1), perPage, r.URL)
+
+	suggestion := ""
+	if query != "" && !exact {
+		suggestion = s.SuggestQuery(query)
+	}
 	data := IndexPageData{
-		Query:   query,
-		Count:   len(filtered),
-		Total:   len(artists),
-		Artists: filtered,
-		User:    userProfile,
+		Query:      query,
+		Suggestion: suggestion,
+		Count:      len(filtered),
+		Total:      len(artists),
+		Artists:    filtered[pagination.From:pagination.To],
+		User:       userProfile,
+		Degraded:   s.LastReport().DegradedFacets(),
+		Filters:    filters,
+		Facets:     ComputeFacets(matched, filters),
+		Sort:       sortKey,
+		Order:      order,
+		Sorts:      SortOptions(sortKey),
+		Pagination: pagination,
 	}
 	s.Render(w, "index.html", data)
 }
@@ -70,7 +85,7 @@ func (s *Server) HandleProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := IndexPageData{
+	data := ProfilePageData{
 		User: &UserProfile{
 			ID:          user.ID,
 			Username:    user.Username,
@@ -82,9 +97,49 @@ func (s *Server) HandleProfile(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
+	if ids, err := ListFavoriteIDs(DB, userID); err != nil {
+		log.Printf("Erreur lecture favoris: %v", err)
+	} else {
+		for _, id := range ids {
+			if art, ok := s.FindArtist(id); ok {
+				data.Favorites = append(data.Favorites, art)
+			}
+		}
+	}
+	if token, err := CalendarToken(DB, userID); err != nil {
+		log.Printf("Erreur jeton calendrier: %v", err)
+	} else {
+		data.CalendarURL = requestBaseURL(r) + "/calendar/" + token + "/favorites.ics"
+	}
+
 	s.Render(w, "profile.html", data)
 }
 
+// currentUserProfile renvoie le profil de l'utilisateur connecté, ou nil.
+func currentUserProfile(r *http.Request) *UserProfile {
+	if !IsAuthenticated(r) {
+		return nil
+	}
+	session, _ := GetSession(r)
+	userID, ok := session.Values["user_id"].(int)
+	if !ok {
+		return nil
+	}
+	user, err := GetUserByID(DB, userID)
+	if err != nil {
+		return nil
+	}
+	return &UserProfile{
+		ID:          user.ID,
+		Username:    user.Username,
+		Email:       user.Email,
+		Pseudo:      getStringValue(user.Pseudo),
+		Bio:         getStringValue(user.Bio),
+		PhotoProfil: getStringValue(user.PhotoProfil),
+		Role:        user.Role,
+	}
+}
+
 func getStringValue(ns sql.NullString) string {
 	if ns.Valid {
 		return ns.String
@@ -112,14 +167,34 @@ func (s *Server) HandleArtist(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	locDates := BuildLocationDates(art.DatesLocations)
-	locationsCoords := GeocodeLocations(art.Locations, art.DatesLocations)
+	locDates := BuildLocationDates(art.Concerts)
+	locationsCoords := LocationsWithCoords(art.Concerts)
+
+	tiers, err := ListPriceTiers(DB)
+	if err != nil {
+		log.Printf("Erreur lecture tarifs: %v", err)
+	}
+	offers := TicketOffers(tiers, art)
+	availability, err := ConcertAvailability(DB, art.ID, time.Now())
+	if err != nil {
+		log.Printf("Erreur lecture jauges: %v", err)
+	}
 
 	data := ArtistPageData{
 		Artist:          art,
 		LocationDates:   locDates,
 		LocationsCoords: locationsCoords,
-		PayPalClientID:  PayPalClientID,
+		Tour:            BuildTour(art),
+		Offers:          offers,
+		SoldOut:         ApplyAvailability(offers, availability),
+		PayPalClientID:  s.payPalClientID(),
+	}
+	if userID, ok := sessionUserID(r); ok {
+		favorite, err := IsFavorite(DB, userID, art.ID)
+		if err != nil {
+			log.Printf("Erreur lecture favori: %v", err)
+		}
+		data.IsFavorite = favorite
 	}
 	s.Render(w, "artist.html", data)
 }
@@ -129,7 +204,7 @@ func (s *Server) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
 		return
 	}
-	if err := s.RefreshData(); err != nil {
+	if err := s.refresher.Refresh(); err != nil {
 		http.Error(w, "Impossible d'actualiser les données", http.StatusBadGateway)
 		return
 	}
@@ -148,7 +223,8 @@ func (s *Server) HandleGeocode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	coords, err := GeocodeLocation(address)
+	// Saisie libre: le cache est consulté mais rien n'y est enregistré
+	coords, err := LookupLocation(r.Context(), address)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -165,12 +241,13 @@ func (s *Server) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Le montant n'est jamais lu depuis la requête: il est calculé à partir
+	// des tarifs enregistrés.
 	var req struct {
-		ArtistID int     `json:"artist_id"`
-		Location string  `json:"location"`
-		Date     string  `json:"date"`
-		Quantity int     `json:"quantity"`
-		Amount   float64 `json:"amount"`
+		ArtistID int    `json:"artist_id"`
+		Location string `json:"location"`
+		Date     string `json:"date"`
+		Quantity int    `json:"quantity"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -178,12 +255,9 @@ func (s *Server) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Quantity <= 0 {
+	if req.Quantity == 0 {
 		req.Quantity = 1
 	}
-	if req.Amount <= 0 {
-		req.Amount = DefaultTicketPrice * float64(req.Quantity)
-	}
 
 	art, ok := s.FindArtist(req.ArtistID)
 	if !ok {
@@ -191,36 +265,110 @@ func (s *Server) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tiers, err := ListPriceTiers(DB)
+	if err != nil {
+		log.Printf("Erreur lecture tarifs: %v", err)
+		http.Error(w, "Erreur lors de la création de la commande", http.StatusInternalServerError)
+		return
+	}
+	quote, err := QuoteTickets(tiers, art, req.Location, req.Date, req.Quantity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := sessionUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	record := Order{
+		UserID:      userID,
+		Provider:    s.payments.Name(),
+		ArtistID:    art.ID,
+		ArtistName:  art.Name,
+		Location:    quote.Location,
+		ConcertDate: quote.Date,
+		Quantity:    quote.Quantity,
+		UnitPrice:   quote.UnitPrice,
+		Total:       quote.Total,
+		Currency:    quote.Currency,
+	}
+	if err := CreateOrder(DB, &record); err != nil {
+		log.Printf("Erreur enregistrement commande: %v", err)
+		http.Error(w, "Erreur lors de la création de la commande", http.StatusInternalServerError)
+		return
+	}
+
+	// Sans places, la commande n'a jamais existé pour l'acheteur: elle est
+	// supprimée plutôt que d'encombrer « Mes billets » d'un échec.
+	if err := HoldSeats(DB, record, time.Now(), SeatHoldTTL); err != nil {
+		if err := DeleteOrder(DB, record.ID); err != nil {
+			log.Printf("Erreur suppression commande %d: %v", record.ID, err)
+		}
+		if errors.Is(err, ErrSoldOut) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Erreur réservation places: %v", err)
+		http.Error(w, "Erreur lors de la création de la commande", http.StatusInternalServerError)
+		return
+	}
+
 	scheme := "https"
 	if r.TLS == nil {
 		scheme = "http"
 	}
 	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
 	returnURL := fmt.Sprintf("%s/paypal/success", baseURL)
-	cancelURL := fmt.Sprintf("%s/artist?id=%d", baseURL, req.ArtistID)
+	cancelURL := fmt.Sprintf("%s/paypal/cancel", baseURL)
 
-	description := fmt.Sprintf("Billet pour %s - %s (%s)", art.Name, req.Location, req.Date)
+	description := fmt.Sprintf("%d billet(s) pour %s - %s (%s)", quote.Quantity, art.Name, FormatLocation(quote.Location), FormatDate(quote.Date))
 
-	order, err := CreatePayPalOrder(s.client, req.Amount, description, returnURL, cancelURL)
+	order, err := s.payments.CreateOrder(r.Context(), PaymentRequest{
+		Amount:      quote.Total,
+		Currency:    quote.Currency,
+		Description: description,
+		ReturnURL:   returnURL,
+		CancelURL:   cancelURL,
+	})
 	if err != nil {
-		log.Printf("Erreur création commande PayPal: %v", err)
+		log.Printf("Erreur création commande %s: %v", s.payments.Name(), err)
+		if err := ReleaseHold(DB, record.ID); err != nil {
+			log.Printf("Erreur libération places commande %d: %v", record.ID, err)
+		}
+		if err := UpdateOrderStatus(DB, record.ID, OrderFailed, err.Error()); err != nil {
+			log.Printf("Erreur mise à jour commande %d: %v", record.ID, err)
+		}
 		http.Error(w, "Erreur lors de la création de la commande", http.StatusInternalServerError)
 		return
 	}
 
-	var approveURL string
-	for _, link := range order.Links {
-		if link.Rel == "approve" {
-			approveURL = link.Href
-			break
+	if err := SetOrderProviderID(DB, record.ID, order.ID); err != nil {
+		log.Printf("Erreur enregistrement commande %s: %v", s.payments.Name(), err)
+		// La commande du prestataire ne pourra jamais être rapprochée: elle
+		// est annulée et la commande locale passe en échec.
+		if err := s.payments.VoidOrder(r.Context(), order.ID); err != nil {
+			log.Printf("Erreur annulation commande %s %s: %v", s.payments.Name(), order.ID, err)
+		}
+		if err := ReleaseHold(DB, record.ID); err != nil {
+			log.Printf("Erreur libération places commande %d: %v", record.ID, err)
 		}
+		if err := UpdateOrderStatus(DB, record.ID, OrderFailed, err.Error()); err != nil {
+			log.Printf("Erreur mise à jour commande %d: %v", record.ID, err)
+		}
+		http.Error(w, "Erreur lors de la création de la commande", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"order_id":    order.ID,
 		"status":      order.Status,
-		"approve_url": approveURL,
+		"approve_url": order.ApproveURL,
+		"total":       quote.Total,
+		"currency":    quote.Currency,
 	})
 }
 
@@ -244,15 +392,34 @@ func (s *Server) HandleCaptureOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	capture, err := CapturePayPalOrder(s.client, req.OrderID)
+	userID, _ := sessionUserID(r)
+	order, err := GetOrderByProviderID(DB, req.OrderID, userID)
+	if errors.Is(err, ErrOrderNotFound) {
+		http.Error(w, "Commande introuvable", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Erreur capture PayPal: %v", err)
+		log.Printf("Erreur lecture commande: %v", err)
+		http.Error(w, "Erreur lors de la capture du paiement", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.settleOrder(&order); err != nil {
+		if errors.Is(err, ErrSoldOut) || errors.Is(err, ErrPaymentNotApproved) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Erreur capture paiement: %v", err)
 		http.Error(w, "Erreur lors de la capture du paiement", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(capture)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"order_id":   order.ProviderOrderID,
+		"status":     order.Status,
+		"capture_id": order.CaptureID,
+	})
 }
 
 func (s *Server) HandlePayPalSuccess(w http.ResponseWriter, r *http.Request) {
@@ -268,18 +435,41 @@ func (s *Server) HandlePayPalSuccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	capture, err := CapturePayPalOrder(s.client, orderID)
+	userID, _ := sessionUserID(r)
+	order, err := GetOrderByProviderID(DB, orderID, userID)
+	if errors.Is(err, ErrOrderNotFound) {
+		http.Error(w, "Commande introuvable", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lecture commande: %v", err)
+		http.Error(w, "Erreur lors de la récupération de la commande", http.StatusInternalServerError)
+		return
+	}
+
+	// La commande n'est marquée approuvée que si le prestataire le confirme:
+	// cette page peut être ouverte sans être passé par l'approbation.
+	err = s.settleOrder(&order)
 	if err != nil {
-		log.Printf("Erreur capture automatique PayPal: %v", err)
+		log.Printf("Erreur capture automatique paiement: %v", err)
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	statusMessage := "traitée avec succès"
-	if capture != nil && capture.Status == "COMPLETED" {
-		statusMessage = "payée et confirmée"
-	} else if err != nil {
+	title := "✅ Paiement réussi !"
+	statusMessage := "payée et confirmée"
+	switch order.Status {
+	case OrderCreated:
+		title = "⏳ Paiement non confirmé"
+		statusMessage = "enregistrée, mais le paiement n'a pas encore été approuvé"
+	case OrderApproved:
 		statusMessage = "en attente de confirmation"
+	case OrderFailed:
+		title = "❌ Paiement non abouti"
+		statusMessage = "refusée par le prestataire de paiement"
+		if errors.Is(err, ErrSoldOut) {
+			statusMessage = "annulée : le concert est complet, aucun montant n'a été débité"
+		}
 	}
 
 	fmt.Fprintf(w, `
@@ -288,19 +478,50 @@ func (s *Server) HandlePayPalSuccess(w http.ResponseWriter, r *http.Request) {
 <head>
 	<meta charset="utf-8">
 	<meta name="viewport" content="width=device-width, initial-scale=1">
-	<title>Paiement réussi - Groupie Tracker</title>
+	<title>Paiement - Groupie Tracker</title>
 	<link rel="stylesheet" href="/static/CSS/styles.css">
 </head>
 <body>
 	<main class="container" style="padding: 4rem 2rem; text-align: center;">
-		<h1 style="color: var(--gold); margin-bottom: 1rem;">✅ Paiement réussi !</h1>
-		<p style="color: var(--muted); margin-bottom: 2rem;">Votre commande #%s a été %s.</p>
-		<p style="color: var(--foreground); margin-bottom: 2rem;">Vous recevrez un email de confirmation sous peu.</p>
-		<a href="/" style="display: inline-block; padding: 0.75rem 2rem; background: var(--gradient-gold); color: var(--bg); text-decoration: none; border-radius: 0.75rem; font-weight: 600; margin-top: 1rem;">Retour à l'accueil</a>
+		<h1 style="color: var(--gold); margin-bottom: 1rem;">%s</h1>
+		<p style="color: var(--muted); margin-bottom: 2rem;">Votre commande n°%d a été %s.</p>
+		<p style="color: var(--foreground); margin-bottom: 2rem;">Retrouvez vos billets dans votre espace « Mes billets ».</p>
+		<a href="/profile/tickets" style="display: inline-block; padding: 0.75rem 2rem; background: var(--gradient-gold); color: var(--bg); text-decoration: none; border-radius: 0.75rem; font-weight: 600; margin-top: 1rem;">Mes billets</a>
+		<a href="/" style="display: inline-block; padding: 0.75rem 2rem; border: 1px solid var(--gold); color: var(--gold); text-decoration: none; border-radius: 0.75rem; font-weight: 600; margin-top: 1rem;">Retour à l'accueil</a>
 	</main>
 </body>
 </html>
-	`, orderID, statusMessage)
+	`, title, order.ID, statusMessage)
+}
+
+// HandlePayPalCancel libère les places d'une commande abandonnée ou refusée
+// chez le prestataire de paiement puis revient sur la page de l'artiste.
+func (s *Server) HandlePayPalCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := sessionUserID(r)
+	order, err := GetOrderByProviderID(DB, r.URL.Query().Get("token"), userID)
+	if err != nil {
+		http.Redirect(w, r, "/home", http.StatusSeeOther)
+		return
+	}
+	// Une commande approuvée a peut-être déjà une capture en cours chez le
+	// prestataire: seules les commandes jamais approuvées sont annulées.
+	if order.Status == OrderCreated {
+		reason := "annulée par l'acheteur"
+		if status, err := s.payments.OrderStatus(r.Context(), order.ProviderOrderID); err == nil && status.Status == PaymentDeclined {
+			reason = "paiement refusé"
+		}
+		if err := ReleaseHold(DB, order.ID); err != nil {
+			log.Printf("Erreur libération places commande %d: %v", order.ID, err)
+		}
+		if err := UpdateOrderStatus(DB, order.ID, OrderFailed, reason); err != nil {
+			log.Printf("Erreur mise à jour commande %d: %v", order.ID, err)
+		}
+	}
+	http.Redirect(w, r, fmt.Sprintf("/artist?id=%d", order.ArtistID), http.StatusSeeOther)
 }
 
 func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,97 @@
+package src
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CachedResponse est une réponse HTTP conservée sur disque avec ses
+// validateurs de cache.
+type CachedResponse struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Body         []byte    `json:"body"`
+}
+
+// ResponseCache stocke une réponse par URL dans un dossier local. Un
+// dossier vide (ou HTTP_CACHE_DIR=off) désactive le cache.
+type ResponseCache struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// responseCache est le cache partagé par FetchJSON.
+var responseCache = newResponseCache(HTTPCacheDir)
+
+func newResponseCache(dir string) *ResponseCache {
+	if dir == "off" {
+		dir = ""
+	}
+	return &ResponseCache{Dir: dir}
+}
+
+func (c *ResponseCache) path(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Load renvoie la dernière réponse valide connue pour url, ou nil.
+func (c *ResponseCache) Load(url string) (*CachedResponse, error) {
+	if c == nil || c.Dir == "" {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lecture cache %s: %w", url, err)
+	}
+	var entry CachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("cache corrompu pour %s: %w", url, err)
+	}
+	return &entry, nil
+}
+
+// Store enregistre la réponse de façon atomique (fichier temporaire puis
+// renommage) pour ne jamais laisser une entrée tronquée.
+func (c *ResponseCache) Store(entry *CachedResponse) error {
+	if c == nil || c.Dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("création dossier cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.Dir, "entry-*.tmp")
+	if err != nil {
+		return fmt.Errorf("écriture cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("écriture cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("écriture cache: %w", err)
+	}
+	return os.Rename(tmp.Name(), c.path(entry.URL))
+}
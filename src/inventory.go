@@ -0,0 +1,354 @@
+package src
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSoldOut signale qu'il ne reste pas assez de places pour la commande.
+var ErrSoldOut = errors.New("plus assez de places disponibles pour ce concert")
+
+// Statuts d'une réservation de places.
+const (
+	HoldActive   = "held"
+	HoldSold     = "sold"
+	HoldReleased = "released"
+)
+
+// ConcertCapacity est la jauge d'un concert et son occupation.
+type ConcertCapacity struct {
+	ArtistID    int
+	Location    string
+	ConcertDate string // au format de l'API
+	Capacity    int
+	Sold        int
+	Held        int // réservations en cours, non expirées
+}
+
+// Remaining renvoie le nombre de places encore disponibles.
+func (c ConcertCapacity) Remaining() int {
+	if left := c.Capacity - c.Sold - c.Held; left > 0 {
+		return left
+	}
+	return 0
+}
+
+func concertKey(location, date string) string {
+	return location + "|" + date
+}
+
+// SeatHold est une réservation de places pour une commande.
+type SeatHold struct {
+	OrderID   int64
+	Quantity  int
+	Status    string
+	ExpiresAt time.Time
+}
+
+// Occupies indique si la réservation occupe encore des places à now: une
+// place vendue l'est définitivement, une réservation en cours jusqu'à son
+// expiration.
+func (h SeatHold) Occupies(now time.Time) bool {
+	return h.Status == HoldSold || (h.Status == HoldActive && h.ExpiresAt.After(now))
+}
+
+// checkSeats vérifie qu'il reste assez de places pour la commande, sans
+// compter sa propre réservation éventuelle.
+func checkSeats(capacity int, holds []SeatHold, order Order, now time.Time) error {
+	taken := 0
+	for _, h := range holds {
+		if h.OrderID != order.ID && h.Occupies(now) {
+			taken += h.Quantity
+		}
+	}
+	if capacity-taken < order.Quantity {
+		return ErrSoldOut
+	}
+	return nil
+}
+
+// lockCapacity crée au besoin la ligne de jauge du concert puis la verrouille
+// jusqu'à la fin de la transaction, ce qui sérialise les réservations
+// concurrentes sur un même concert.
+func lockCapacity(tx *sql.Tx, artistID int, location, date string) (int, error) {
+	const insert = `INSERT IGNORE INTO concert_capacity (artist_id, location, concert_date, capacity) VALUES (?, ?, ?, ?)`
+	if _, err := tx.Exec(insert, artistID, location, date, DefaultConcertCapacity); err != nil {
+		return 0, fmt.Errorf("création jauge: %w", err)
+	}
+	var capacity int
+	const query = `SELECT capacity FROM concert_capacity WHERE artist_id = ? AND location = ? AND concert_date = ? FOR UPDATE`
+	if err := tx.QueryRow(query, artistID, location, date).Scan(&capacity); err != nil {
+		return 0, fmt.Errorf("lecture jauge: %w", err)
+	}
+	return capacity, nil
+}
+
+// HoldSeats réserve les places d'une commande jusqu'à now+ttl. Les
+// réservations expirées ne comptent plus, même avant le passage du
+// nettoyage. Rappeler HoldSeats pour la même commande prolonge sa
+// réservation, ou la reprend si elle a été libérée entre-temps.
+func HoldSeats(db *sql.DB, order Order, now time.Time, ttl time.Duration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("réservation places: %w", err)
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockCapacity(tx, order.ArtistID, order.Location, order.ConcertDate)
+	if err != nil {
+		return err
+	}
+	const query = `SELECT order_id, quantity, status, expires_at FROM seat_holds
+WHERE artist_id = ? AND location = ? AND concert_date = ? AND status IN ('sold', 'held')`
+	rows, err := tx.Query(query, order.ArtistID, order.Location, order.ConcertDate)
+	if err != nil {
+		return fmt.Errorf("lecture réservations: %w", err)
+	}
+	var holds []SeatHold
+	for rows.Next() {
+		var h SeatHold
+		if err := rows.Scan(&h.OrderID, &h.Quantity, &h.Status, &h.ExpiresAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("lecture réservations: %w", err)
+		}
+		holds = append(holds, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("lecture réservations: %w", err)
+	}
+	if err := checkSeats(capacity, holds, order, now); err != nil {
+		return err
+	}
+
+	const upsert = `INSERT INTO seat_holds (order_id, artist_id, location, concert_date, quantity, status, expires_at)
+VALUES (?, ?, ?, ?, ?, 'held', ?)
+ON DUPLICATE KEY UPDATE status = IF(status = 'sold', status, 'held'), expires_at = VALUES(expires_at)`
+	if _, err := tx.Exec(upsert, order.ID, order.ArtistID, order.Location, order.ConcertDate, order.Quantity, now.Add(ttl)); err != nil {
+		return fmt.Errorf("réservation places: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("réservation places: %w", err)
+	}
+	return nil
+}
+
+// ReleaseHold libère la réservation d'une commande non encaissée.
+func ReleaseHold(db *sql.DB, orderID int64) error {
+	if _, err := db.Exec(`UPDATE seat_holds SET status = 'released' WHERE order_id = ? AND status = 'held'`, orderID); err != nil {
+		return fmt.Errorf("libération places: %w", err)
+	}
+	return nil
+}
+
+// ReleaseExpiredHolds libère les réservations expirées et passe en échec les
+// commandes dont le paiement n'a pas commencé. Elle renvoie le nombre de
+// réservations libérées.
+func ReleaseExpiredHolds(db *sql.DB, now time.Time) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("libération réservations: %w", err)
+	}
+	defer tx.Rollback()
+
+	const expireOrders = `UPDATE orders o JOIN seat_holds h ON h.order_id = o.id
+SET o.status = 'failed', o.error = 'réservation expirée'
+WHERE h.status = 'held' AND h.expires_at <= ? AND o.status = 'created'`
+	if _, err := tx.Exec(expireOrders, now); err != nil {
+		return 0, fmt.Errorf("expiration commandes: %w", err)
+	}
+	res, err := tx.Exec(`UPDATE seat_holds SET status = 'released' WHERE status = 'held' AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, fmt.Errorf("libération réservations: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("libération réservations: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// RunHoldSweeper libère périodiquement les réservations expirées jusqu'à
+// l'annulation du contexte.
+func RunHoldSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if DB == nil {
+				continue
+			}
+			count, err := ReleaseExpiredHolds(DB, now)
+			if err != nil {
+				log.Printf("Libération des réservations expirées impossible: %v", err)
+			} else if count > 0 {
+				log.Printf("%d réservation(s) expirée(s) libérée(s)", count)
+			}
+		}
+	}
+}
+
+// SetConcertCapacity fixe la jauge d'un concert.
+func SetConcertCapacity(db *sql.DB, artistID int, location, date string, capacity int) error {
+	const query = `INSERT INTO concert_capacity (artist_id, location, concert_date, capacity) VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE capacity = VALUES(capacity)`
+	if _, err := db.Exec(query, artistID, location, date, capacity); err != nil {
+		return fmt.Errorf("enregistrement jauge: %w", err)
+	}
+	return nil
+}
+
+// ConcertAvailability renvoie l'occupation des concerts d'un artiste, par
+// lieu et date. Un concert absent de la table a la jauge par défaut.
+func ConcertAvailability(db *sql.DB, artistID int, now time.Time) (map[string]ConcertCapacity, error) {
+	availability := make(map[string]ConcertCapacity)
+	rows, err := db.Query(`SELECT location, concert_date, capacity FROM concert_capacity WHERE artist_id = ?`, artistID)
+	if err != nil {
+		return nil, fmt.Errorf("lecture jauges: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := ConcertCapacity{ArtistID: artistID}
+		if err := rows.Scan(&c.Location, &c.ConcertDate, &c.Capacity); err != nil {
+			return nil, fmt.Errorf("lecture jauges: %w", err)
+		}
+		availability[concertKey(c.Location, c.ConcertDate)] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("lecture jauges: %w", err)
+	}
+
+	const query = `SELECT location, concert_date,
+COALESCE(SUM(CASE WHEN status = 'sold' THEN quantity ELSE 0 END), 0),
+COALESCE(SUM(CASE WHEN status = 'held' AND expires_at > ? THEN quantity ELSE 0 END), 0)
+FROM seat_holds WHERE artist_id = ? AND status IN ('sold', 'held')
+GROUP BY location, concert_date`
+	holds, err := db.Query(query, now, artistID)
+	if err != nil {
+		return nil, fmt.Errorf("lecture réservations: %w", err)
+	}
+	defer holds.Close()
+	for holds.Next() {
+		var location, date string
+		var sold, held int
+		if err := holds.Scan(&location, &date, &sold, &held); err != nil {
+			return nil, fmt.Errorf("lecture réservations: %w", err)
+		}
+		key := concertKey(location, date)
+		c, ok := availability[key]
+		if !ok {
+			c = ConcertCapacity{ArtistID: artistID, Location: location, ConcertDate: date, Capacity: DefaultConcertCapacity}
+		}
+		c.Sold, c.Held = sold, held
+		availability[key] = c
+	}
+	return availability, holds.Err()
+}
+
+// ApplyAvailability complète les offres avec les places restantes et
+// renvoie les lieux dont toutes les dates sont complètes.
+func ApplyAvailability(offers map[string][]TicketOffer, availability map[string]ConcertCapacity) map[string]bool {
+	soldOut := make(map[string]bool)
+	for location, list := range offers {
+		full := true
+		for i := range list {
+			remaining := DefaultConcertCapacity
+			if c, ok := availability[concertKey(location, list[i].Date)]; ok {
+				remaining = c.Remaining()
+			}
+			list[i].Remaining = remaining
+			list[i].SoldOut = remaining == 0
+			if remaining < list[i].MaxPerOrder {
+				list[i].MaxPerOrder = remaining
+			}
+			if !list[i].SoldOut {
+				full = false
+			}
+		}
+		soldOut[location] = full
+	}
+	return soldOut
+}
+
+// ListConcertCapacities renvoie les jauges enregistrées avec leur
+// occupation, pour l'administration.
+func ListConcertCapacities(db *sql.DB, now time.Time) ([]ConcertCapacity, error) {
+	const query = `SELECT c.artist_id, c.location, c.concert_date, c.capacity,
+COALESCE(SUM(CASE WHEN h.status = 'sold' THEN h.quantity ELSE 0 END), 0),
+COALESCE(SUM(CASE WHEN h.status = 'held' AND h.expires_at > ? THEN h.quantity ELSE 0 END), 0)
+FROM concert_capacity c
+LEFT JOIN seat_holds h ON h.artist_id = c.artist_id AND h.location = c.location AND h.concert_date = c.concert_date
+GROUP BY c.artist_id, c.location, c.concert_date, c.capacity
+ORDER BY c.artist_id, c.concert_date, c.location`
+	rows, err := db.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("lecture jauges: %w", err)
+	}
+	defer rows.Close()
+	var list []ConcertCapacity
+	for rows.Next() {
+		var c ConcertCapacity
+		if err := rows.Scan(&c.ArtistID, &c.Location, &c.ConcertDate, &c.Capacity, &c.Sold, &c.Held); err != nil {
+			return nil, fmt.Errorf("lecture jauges: %w", err)
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// HandleAdminSetCapacity fixe la jauge d'un concert existant.
+func (s *Server) HandleAdminSetCapacity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+	artistID, err := strconv.Atoi(r.FormValue("artist_id"))
+	if err != nil {
+		http.Error(w, "Artiste invalide", http.StatusBadRequest)
+		return
+	}
+	art, ok := s.FindArtist(artistID)
+	if !ok {
+		http.Error(w, "Artiste non trouvé", http.StatusNotFound)
+		return
+	}
+	location := strings.ToLower(strings.TrimSpace(r.FormValue("location")))
+	date, ok := normalizeConcertDate(r.FormValue("date"))
+	if !ok {
+		http.Error(w, "Date invalide", http.StatusBadRequest)
+		return
+	}
+	if _, ok := findConcert(art, location, date); !ok {
+		http.Error(w, ErrUnknownConcert.Error(), http.StatusBadRequest)
+		return
+	}
+	capacity, err := strconv.Atoi(strings.TrimSpace(r.FormValue("capacity")))
+	if err != nil || capacity < 0 {
+		http.Error(w, "Capacité invalide", http.StatusBadRequest)
+		return
+	}
+	if err := SetConcertCapacity(DB, artistID, location, date, capacity); err != nil {
+		log.Printf("Erreur enregistrement jauge: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement de la jauge", http.StatusInternalServerError)
+		return
+	}
+	message := url.Values{"message": {"Jauge enregistrée"}}
+	http.Redirect(w, r, "/admin/prices?"+message.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,88 @@
+package src
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckSeats(t *testing.T) {
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	order := Order{ID: 10, Quantity: 2}
+	tests := []struct {
+		name     string
+		capacity int
+		holds    []SeatHold
+		wantErr  error
+	}{
+		{"salle vide", 10, nil, nil},
+		{"dernières places", 10, []SeatHold{{OrderID: 1, Quantity: 8, Status: HoldSold}}, nil},
+		{"complet par les ventes", 10, []SeatHold{{OrderID: 1, Quantity: 9, Status: HoldSold}}, ErrSoldOut},
+		{"complet par une réservation en cours", 10, []SeatHold{
+			{OrderID: 1, Quantity: 5, Status: HoldSold},
+			{OrderID: 2, Quantity: 4, Status: HoldActive, ExpiresAt: now.Add(time.Minute)},
+		}, ErrSoldOut},
+		{"réservation expirée ignorée", 10, []SeatHold{
+			{OrderID: 1, Quantity: 5, Status: HoldSold},
+			{OrderID: 2, Quantity: 4, Status: HoldActive, ExpiresAt: now.Add(-time.Second)},
+		}, nil},
+		{"réservation expirant maintenant ignorée", 10, []SeatHold{
+			{OrderID: 2, Quantity: 9, Status: HoldActive, ExpiresAt: now},
+		}, nil},
+		{"réservation libérée ignorée", 10, []SeatHold{{OrderID: 2, Quantity: 9, Status: HoldReleased, ExpiresAt: now.Add(time.Hour)}}, nil},
+		{"propre réservation non comptée", 2, []SeatHold{{OrderID: 10, Quantity: 2, Status: HoldActive, ExpiresAt: now.Add(time.Minute)}}, nil},
+		{"jauge réduite sous les ventes", 5, []SeatHold{{OrderID: 1, Quantity: 6, Status: HoldSold}}, ErrSoldOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSeats(tt.capacity, tt.holds, order, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkSeats() = %v, attendu %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyAvailability(t *testing.T) {
+	offers := map[string][]TicketOffer{
+		"paris-france": {
+			{Date: "23-08-2019", MaxPerOrder: 10},
+			{Date: "24-08-2019", MaxPerOrder: 10},
+		},
+		"lyon-france": {{Date: "25-08-2019", MaxPerOrder: 10}},
+		"nice-france": {{Date: "26-08-2019", MaxPerOrder: 4}},
+	}
+	availability := map[string]ConcertCapacity{
+		concertKey("paris-france", "23-08-2019"): {Capacity: 100, Sold: 97},
+		concertKey("paris-france", "24-08-2019"): {Capacity: 100, Sold: 100},
+		concertKey("lyon-france", "25-08-2019"):  {Capacity: 50, Sold: 40, Held: 15},
+	}
+	soldOut := ApplyAvailability(offers, availability)
+
+	tests := []struct {
+		location  string
+		index     int
+		remaining int
+		max       int
+		soldOut   bool
+	}{
+		{"paris-france", 0, 3, 3, false},
+		{"paris-france", 1, 0, 0, true},
+		{"lyon-france", 0, 0, 0, true},
+		{"nice-france", 0, DefaultConcertCapacity, 4, false},
+	}
+	for _, tt := range tests {
+		offer := offers[tt.location][tt.index]
+		if offer.Remaining != tt.remaining || offer.MaxPerOrder != tt.max || offer.SoldOut != tt.soldOut {
+			t.Errorf("%s %s: %d place(s), max %d, complet %v; attendu %d, %d, %v",
+				tt.location, offer.Date, offer.Remaining, offer.MaxPerOrder, offer.SoldOut, tt.remaining, tt.max, tt.soldOut)
+		}
+	}
+
+	wantSoldOut := map[string]bool{"paris-france": false, "lyon-france": true, "nice-france": false}
+	for location, want := range wantSoldOut {
+		if soldOut[location] != want {
+			t.Errorf("lieu %s complet = %v, attendu %v", location, soldOut[location], want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package src
 
+import "time"
+
 type Artist struct {
 	ID              int                 `json:"id"`
 	Image           string              `json:"image"`
@@ -13,6 +15,8 @@ type Artist struct {
 	Locations       []string            `json:"-"`
 	ConcertDates    []string            `json:"-"`
 	DatesLocations  map[string][]string `json:"-"`
+	MissingFacets   []string            `json:"-"` // facettes indisponibles lors de la dernière actualisation
+	Concerts        []Concert           `json:"-"` // concerts typés, triés par date
 }
 
 type LocationsPayload struct {
@@ -36,12 +40,47 @@ type RelationsPayload struct {
 	} `json:"index"`
 }
 
+// ByID indexe les lieux par identifiant d'artiste.
+func (p LocationsPayload) ByID() map[int][]string {
+	result := make(map[int][]string, len(p.Index))
+	for _, entry := range p.Index {
+		result[entry.ID] = entry.Locations
+	}
+	return result
+}
+
+// ByID indexe les dates par identifiant d'artiste.
+func (p DatesPayload) ByID() map[int][]string {
+	result := make(map[int][]string, len(p.Index))
+	for _, entry := range p.Index {
+		result[entry.ID] = entry.Dates
+	}
+	return result
+}
+
+// ByID indexe les relations dates/lieux par identifiant d'artiste.
+func (p RelationsPayload) ByID() map[int]map[string][]string {
+	result := make(map[int]map[string][]string, len(p.Index))
+	for _, entry := range p.Index {
+		result[entry.ID] = entry.DatesLocations
+	}
+	return result
+}
+
 type IndexPageData struct {
-	Query   string
-	Count   int
-	Total   int
-	Artists []Artist
-	User    *UserProfile // Informations de l'utilisateur connecté
+	Query      string
+	Suggestion string // correction proposée quand la requête n'a aucun résultat exact
+	Count      int
+	Total      int
+	Artists    []Artist
+	User       *UserProfile // Informations de l'utilisateur connecté
+	Degraded   []string     // facettes absentes de la dernière actualisation
+	Filters    ArtistFilters
+	Facets     Facets
+	Sort       string
+	Order      string
+	Sorts      []SortOption
+	Pagination Pagination
 }
 
 type UserProfile struct {
@@ -59,6 +98,26 @@ type AdminUsersPageData struct {
 	User  *UserProfile // Utilisateur connecté (admin)
 }
 
+type AdminGeocodesPageData struct {
+	Entries []GeocodeEntry
+	Failed  int
+	Now     time.Time
+	Message string
+	User    *UserProfile
+}
+
+type AdminPricesPageData struct {
+	Tiers        []PriceTier
+	Capacities   []ConcertCapacity
+	Artists      []Artist
+	ArtistNames  map[int]string
+	DefaultPrice float64
+	MaxPerOrder  int
+	Capacity     int
+	Message      string
+	User         *UserProfile
+}
+
 type UserDisplay struct {
 	ID          int
 	Username    string
@@ -71,19 +130,67 @@ type UserDisplay struct {
 }
 
 type LocationDates struct {
-	Raw    string
-	Pretty string
-	Dates  []string
-	Count  int
+	Raw      string
+	Pretty   string
+	Concerts []Concert
+	Count    int
 }
 
 type ArtistPageData struct {
 	Artist          Artist
 	LocationDates   []LocationDates
 	LocationsCoords []LocationWithCoords
+	Tour            Tour
+	IsFavorite      bool
+	Offers          map[string][]TicketOffer // par clé de lieu
+	SoldOut         map[string]bool          // lieux dont toutes les dates sont complètes
 	PayPalClientID  string
 }
 
+type ProfilePageData struct {
+	User        *UserProfile
+	Favorites   []Artist
+	CalendarURL string
+}
+
+type FakePaymentPageData struct {
+	Payment FakePayment
+}
+
+type TicketsPageData struct {
+	Orders  []Order
+	Tickets []Ticket
+	Message string
+	User    *UserProfile
+}
+
+type WhatsNewPageData struct {
+	Changes  []CatalogChange
+	Page     int
+	PrevPage int
+	NextPage int
+	Total    int
+	User     *UserProfile
+}
+
+type MapPageData struct {
+	Filters    ConcertFilters
+	Countries  []FacetCount
+	Artists    []FacetCount
+	GeoJSONURL string
+	Count      int
+	Unresolved int
+	User       *UserProfile
+}
+
+type NearbyPageData struct {
+	Query    NearbyQuery
+	Results  []NearbyConcert
+	Searched bool
+	Error    string
+	User     *UserProfile
+}
+
 type LoginPageData struct {
 	Error   string
 	Message string
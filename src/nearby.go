@@ -0,0 +1,193 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EarthRadiusKm est le rayon terrestre moyen utilisé par Haversine.
+const EarthRadiusKm = 6371.0
+
+// Haversine renvoie la distance orthodromique entre deux points, en km.
+func Haversine(a, b Coordinates) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// NearbyConcert est un concert situé dans le rayon de recherche.
+type NearbyConcert struct {
+	ArtistID    int         `json:"artist_id"`
+	ArtistName  string      `json:"artist"`
+	Location    string      `json:"location"`
+	Date        time.Time   `json:"date"`
+	Coordinates Coordinates `json:"coordinates"`
+	DistanceKm  float64     `json:"distance_km"`
+	URL         string      `json:"url"`
+}
+
+// NearbyQuery décrit une recherche de proximité: un point de départ (lat/lon
+// ou nom de lieu), un rayon en km et une fenêtre de dates facultative.
+type NearbyQuery struct {
+	Place     string
+	Origin    Coordinates
+	HasOrigin bool
+	RadiusKm  float64
+	Filters   ConcertFilters
+}
+
+// ParseNearbyQuery lit les paramètres place, lat, lon, radius, from et to.
+func ParseNearbyQuery(values url.Values) (NearbyQuery, error) {
+	q := NearbyQuery{
+		Place:    strings.TrimSpace(values.Get("place")),
+		RadiusKm: DefaultNearbyRadiusKm,
+		Filters:  ParseConcertFilters(url.Values{"from": {values.Get("from")}, "to": {values.Get("to")}}),
+	}
+	if values.Get("lat") != "" || values.Get("lon") != "" {
+		lat, errLat := strconv.ParseFloat(values.Get("lat"), 64)
+		lon, errLon := strconv.ParseFloat(values.Get("lon"), 64)
+		if errLat != nil || errLon != nil || !isFinite(lat) || !isFinite(lon) || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+			return q, errors.New("coordonnées invalides")
+		}
+		q.Origin = Coordinates{Latitude: lat, Longitude: lon}
+		q.HasOrigin = true
+	}
+	if value := values.Get("radius"); value != "" {
+		radius, err := strconv.ParseFloat(value, 64)
+		if err != nil || !isFinite(radius) || radius <= 0 {
+			return q, errors.New("rayon invalide")
+		}
+		q.RadiusKm = math.Min(radius, MaxNearbyRadiusKm)
+	}
+	return q, nil
+}
+
+// isFinite écarte NaN et ±Inf, acceptés par strconv.ParseFloat mais qui
+// faussent les comparaisons de bornes.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// FindNearbyConcerts renvoie les concerts situés à moins de radiusKm de
+// origin, du plus proche au plus lointain, à partir des coordonnées
+// précalculées (les lieux non localisés sont ignorés).
+func FindNearbyConcerts(artists []Artist, origin Coordinates, radiusKm float64, filters ConcertFilters) []NearbyConcert {
+	var results []NearbyConcert
+	for _, art := range artists {
+		for _, c := range art.Concerts {
+			if !filters.Matches(c) {
+				continue
+			}
+			entry, ok := CachedGeocode(c.Raw)
+			if !ok || entry.Status == GeocodeFailed {
+				continue
+			}
+			distance := Haversine(origin, entry.Coordinates)
+			if distance > radiusKm {
+				continue
+			}
+			results = append(results, NearbyConcert{
+				ArtistID:    art.ID,
+				ArtistName:  art.Name,
+				Location:    c.Location(),
+				Date:        c.Date,
+				Coordinates: entry.Coordinates,
+				DistanceKm:  roundKm(distance),
+				URL:         "/artist?id=" + strconv.Itoa(art.ID),
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].DistanceKm != results[j].DistanceKm {
+			return results[i].DistanceKm < results[j].DistanceKm
+		}
+		return results[i].Date.Before(results[j].Date)
+	})
+	if len(results) > MaxNearbyResults {
+		results = results[:MaxNearbyResults]
+	}
+	return results
+}
+
+// resolveNearby complète l'origine de la recherche en géocodant le lieu
+// saisi lorsque aucune coordonnée n'est fournie. Le résultat n'est pas
+// conservé dans le cache de géocodage.
+func resolveNearby(ctx context.Context, q *NearbyQuery) error {
+	if q.HasOrigin {
+		return nil
+	}
+	if q.Place == "" {
+		return errors.New("indiquez un lieu ou des coordonnées")
+	}
+	coords, err := LookupLocation(ctx, q.Place)
+	if err != nil {
+		return errors.New("lieu introuvable: " + q.Place)
+	}
+	q.Origin = coords
+	q.HasOrigin = true
+	return nil
+}
+
+// HandleNearby affiche les concerts proches d'un lieu.
+func (s *Server) HandleNearby(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := ParseNearbyQuery(r.URL.Query())
+	data := NearbyPageData{Query: q, User: currentUserProfile(r)}
+	if err == nil && (q.Place != "" || q.HasOrigin) {
+		err = resolveNearby(r.Context(), &q)
+		if err == nil {
+			data.Query = q
+			data.Searched = true
+			data.Results = FindNearbyConcerts(s.ListArtists(), q.Origin, q.RadiusKm, q.Filters)
+		}
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	s.Render(w, "nearby.html", data)
+}
+
+// HandleNearbyAPI renvoie en JSON les concerts proches d'un lieu.
+func (s *Server) HandleNearbyAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := ParseNearbyQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := resolveNearby(r.Context(), &q); err != nil {
+		status := http.StatusNotFound
+		if q.Place == "" {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	results := FindNearbyConcerts(s.ListArtists(), q.Origin, q.RadiusKm, q.Filters)
+	if results == nil {
+		results = []NearbyConcert{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"origin":    q.Origin,
+		"radius_km": q.RadiusKm,
+		"results":   results,
+	})
+}
@@ -0,0 +1,41 @@
+package src
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseNearbyQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+		radius  float64
+	}{
+		{"rayon par défaut", "lat=48.85&lon=2.35", false, DefaultNearbyRadiusKm},
+		{"rayon plafonné", "lat=48.85&lon=2.35&radius=100000", false, MaxNearbyRadiusKm},
+		{"rayon NaN", "lat=48.85&lon=2.35&radius=NaN", true, 0},
+		{"rayon infini", "lat=48.85&lon=2.35&radius=Inf", true, 0},
+		{"rayon négatif", "lat=48.85&lon=2.35&radius=-5", true, 0},
+		{"latitude NaN", "lat=NaN&lon=2.35", true, 0},
+		{"longitude NaN", "lat=48.85&lon=nan", true, 0},
+		{"latitude infinie", "lat=-Inf&lon=2.35", true, 0},
+		{"latitude hors bornes", "lat=91&lon=2.35", true, 0},
+		{"longitude manquante", "lat=48.85", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := ParseNearbyQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNearbyQuery(%q) erreur = %v, attendu erreur: %v", tt.query, err, tt.wantErr)
+			}
+			if !tt.wantErr && q.RadiusKm != tt.radius {
+				t.Errorf("ParseNearbyQuery(%q) rayon = %v, attendu %v", tt.query, q.RadiusKm, tt.radius)
+			}
+		})
+	}
+}
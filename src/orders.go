@@ -0,0 +1,556 @@
+package src
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cycle de vie d'une commande de billets.
+const (
+	OrderCreated  = "created"  // commande enregistrée, en attente du paiement
+	OrderApproved = "approved" // paiement approuvé par l'acheteur, non capturé
+	OrderCaptured = "captured" // paiement encaissé, billets émis
+	OrderFailed   = "failed"
+	OrderRefunded = "refunded"
+)
+
+// Statuts d'un billet: un billet d'une commande remboursée est annulé.
+const (
+	TicketValid = "valid"
+	TicketVoid  = "void"
+)
+
+// ErrNotRefundable signale une commande qui ne peut pas (ou plus) être
+// remboursée.
+var ErrNotRefundable = errors.New("commande non remboursable")
+
+// ErrOrderNotFound signale une commande inconnue ou appartenant à un autre
+// utilisateur.
+var ErrOrderNotFound = errors.New("commande introuvable")
+
+// Order est une commande de billets pour un concert.
+type Order struct {
+	ID              int64
+	UserID          int
+	Provider        string
+	ProviderOrderID string
+	ArtistID        int
+	ArtistName      string
+	Location        string
+	ConcertDate     string
+	Quantity        int
+	UnitPrice       float64
+	Total           float64
+	Currency        string
+	Status          string
+	CaptureID       string
+	Error           string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// StatusLabel renvoie le statut de la commande en français.
+func (o Order) StatusLabel() string {
+	switch o.Status {
+	case OrderCreated:
+		return "En attente de paiement"
+	case OrderApproved:
+		return "Paiement approuvé"
+	case OrderCaptured:
+		return "Payée"
+	case OrderFailed:
+		return "Échec du paiement"
+	case OrderRefunded:
+		return "Remboursée"
+	default:
+		return o.Status
+	}
+}
+
+// Refundable indique si l'acheteur peut encore se faire rembourser la
+// commande: elle doit être payée et le concert pas encore commencé.
+func (o Order) Refundable() bool {
+	return refundAllowed(o, time.Now(), false) == nil
+}
+
+// refundAllowed vérifie qu'une commande peut être remboursée à now. Un
+// administrateur peut rembourser une commande payée à tout moment,
+// l'acheteur seulement avant le jour du concert.
+func refundAllowed(order Order, now time.Time, admin bool) error {
+	if order.Status != OrderCaptured || order.CaptureID == "" {
+		return ErrNotRefundable
+	}
+	if admin {
+		return nil
+	}
+	date, err := time.ParseInLocation(APIDateLayout, order.ConcertDate, now.Location())
+	if err != nil || !now.Before(date) {
+		return fmt.Errorf("%w: le concert a déjà eu lieu", ErrNotRefundable)
+	}
+	return nil
+}
+
+// Ticket est un billet émis après l'encaissement d'une commande.
+type Ticket struct {
+	ID          int64
+	OrderID     int64
+	Code        string
+	ArtistID    int
+	ArtistName  string
+	Location    string
+	ConcertDate string
+	Status      string
+	CreatedAt   time.Time
+}
+
+// CreateOrder enregistre une nouvelle commande au statut "created".
+func CreateOrder(db *sql.DB, order *Order) error {
+	order.Status = OrderCreated
+	const query = `INSERT INTO orders (user_id, provider, artist_id, artist_name, location, concert_date, quantity, unit_price, total, currency, status)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Exec(query, order.UserID, order.Provider, order.ArtistID, order.ArtistName, order.Location, order.ConcertDate,
+		order.Quantity, order.UnitPrice, order.Total, order.Currency, order.Status)
+	if err != nil {
+		return fmt.Errorf("création commande: %w", err)
+	}
+	order.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("création commande: %w", err)
+	}
+	return nil
+}
+
+// SetOrderProviderID associe la commande à l'identifiant du prestataire de
+// paiement.
+func SetOrderProviderID(db *sql.DB, orderID int64, providerOrderID string) error {
+	if _, err := db.Exec(`UPDATE orders SET provider_order_id = ? WHERE id = ?`, providerOrderID, orderID); err != nil {
+		return fmt.Errorf("mise à jour commande: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrder supprime une commande qui n'a pas abouti à une réservation.
+// Une commande déjà transmise au prestataire n'est jamais supprimée.
+func DeleteOrder(db *sql.DB, orderID int64) error {
+	if _, err := db.Exec(`DELETE FROM orders WHERE id = ? AND status = ? AND provider_order_id IS NULL`, orderID, OrderCreated); err != nil {
+		return fmt.Errorf("suppression commande: %w", err)
+	}
+	return nil
+}
+
+// UpdateOrderStatus change le statut d'une commande. Une commande encaissée
+// ne peut plus repasser à un statut antérieur, seulement être remboursée.
+func UpdateOrderStatus(db *sql.DB, orderID int64, status, errMsg string) error {
+	if len(errMsg) > 500 {
+		errMsg = errMsg[:500]
+	}
+	const query = `UPDATE orders SET status = ?, error = ?
+WHERE id = ? AND (status NOT IN ('captured', 'refunded') OR ? = 'refunded')`
+	if _, err := db.Exec(query, status, errMsg, orderID, status); err != nil {
+		return fmt.Errorf("mise à jour statut commande: %w", err)
+	}
+	return nil
+}
+
+// CaptureOrder marque la commande comme encaissée, convertit sa réservation
+// de places en vente et émet ses billets dans la même transaction. Rejouer
+// l'appel sur une commande déjà encaissée n'émet pas de nouveaux billets.
+func CaptureOrder(db *sql.DB, order *Order, captureID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("capture commande: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE orders SET status = ?, capture_id = ?, error = '' WHERE id = ? AND status IN (?, ?, ?)`,
+		OrderCaptured, captureID, order.ID, OrderCreated, OrderApproved, OrderFailed)
+	if err != nil {
+		return fmt.Errorf("capture commande: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return tx.Commit()
+	}
+	if _, err := tx.Exec(`UPDATE seat_holds SET status = ? WHERE order_id = ?`, HoldSold, order.ID); err != nil {
+		return fmt.Errorf("confirmation places: %w", err)
+	}
+	for i := 0; i < order.Quantity; i++ {
+		code, err := newTicketCode()
+		if err != nil {
+			return err
+		}
+		const query = `INSERT INTO tickets (order_id, user_id, artist_id, location, concert_date, code, status) VALUES (?, ?, ?, ?, ?, ?, ?)`
+		if _, err := tx.Exec(query, order.ID, order.UserID, order.ArtistID, order.Location, order.ConcertDate, code, TicketValid); err != nil {
+			return fmt.Errorf("émission billet: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("capture commande: %w", err)
+	}
+	order.Status = OrderCaptured
+	order.CaptureID = captureID
+	return nil
+}
+
+// RefundOrder marque une commande payée comme remboursée, annule ses
+// billets et remet ses places en vente, dans la même transaction.
+func RefundOrder(db *sql.DB, order *Order) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("remboursement commande: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE orders SET status = ?, error = '' WHERE id = ? AND status = ?`, OrderRefunded, order.ID, OrderCaptured)
+	if err != nil {
+		return fmt.Errorf("remboursement commande: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotRefundable
+	}
+	if _, err := tx.Exec(`UPDATE tickets SET status = ? WHERE order_id = ?`, TicketVoid, order.ID); err != nil {
+		return fmt.Errorf("annulation billets: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE seat_holds SET status = ? WHERE order_id = ?`, HoldReleased, order.ID); err != nil {
+		return fmt.Errorf("libération places: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("remboursement commande: %w", err)
+	}
+	order.Status = OrderRefunded
+	return nil
+}
+
+// MarkCapturePending enregistre une capture acceptée mais non encore
+// encaissée par le prestataire. La commande reste approuvée et ses places
+// sont confirmées pour ne pas être revendues pendant l'attente.
+func MarkCapturePending(db *sql.DB, order *Order, captureID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("capture en attente: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE orders SET status = ?, capture_id = ?, error = 'capture en attente' WHERE id = ? AND status IN (?, ?, ?)`,
+		OrderApproved, captureID, order.ID, OrderCreated, OrderApproved, OrderFailed)
+	if err != nil {
+		return fmt.Errorf("capture en attente: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return tx.Commit()
+	}
+	if _, err := tx.Exec(`UPDATE seat_holds SET status = ? WHERE order_id = ?`, HoldSold, order.ID); err != nil {
+		return fmt.Errorf("confirmation places: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("capture en attente: %w", err)
+	}
+	order.Status = OrderApproved
+	order.CaptureID = captureID
+	return nil
+}
+
+// FailOrder passe en échec une commande non encaissée et libère ses places,
+// y compris celles confirmées par une capture en attente qui a été refusée.
+func FailOrder(db *sql.DB, order *Order, errMsg string) error {
+	if len(errMsg) > 500 {
+		errMsg = errMsg[:500]
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("échec commande: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE orders SET status = ?, error = ? WHERE id = ? AND status IN (?, ?, ?)`,
+		OrderFailed, errMsg, order.ID, OrderCreated, OrderApproved, OrderFailed)
+	if err != nil {
+		return fmt.Errorf("échec commande: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return tx.Commit()
+	}
+	if _, err := tx.Exec(`UPDATE seat_holds SET status = ? WHERE order_id = ?`, HoldReleased, order.ID); err != nil {
+		return fmt.Errorf("libération places: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("échec commande: %w", err)
+	}
+	order.Status = OrderFailed
+	return nil
+}
+
+// ErrPaymentNotApproved signale une commande que l'acheteur n'a pas encore
+// approuvée chez le prestataire.
+var ErrPaymentNotApproved = errors.New("paiement non approuvé par l'acheteur")
+
+// settleOrder capture le paiement d'une commande auprès du prestataire et met
+// à jour son statut. L'état de la commande est d'abord relu chez le
+// prestataire: une capture déjà demandée (en attente ou encaissée) n'est
+// jamais rejouée, et une commande dont le paiement a pu aboutir n'est jamais
+// passée en échec. Les places sont réservées à nouveau avant la capture: si
+// la réservation a expiré et que le concert est complet, le paiement n'est
+// pas encaissé.
+func (s *Server) settleOrder(order *Order) error {
+	if order.Status == OrderCaptured || order.Status == OrderRefunded {
+		return nil
+	}
+	if order.Provider != s.payments.Name() {
+		return fmt.Errorf("commande %d créée chez %s, prestataire actif: %s", order.ID, order.Provider, s.payments.Name())
+	}
+	ctx := context.Background()
+	status, err := s.payments.OrderStatus(ctx, order.ProviderOrderID)
+	if err != nil {
+		return fmt.Errorf("état commande %d: %w", order.ID, err)
+	}
+	if status.CaptureID != "" {
+		return s.applyCapture(order, PaymentCapture{ID: status.CaptureID, Status: status.CaptureStatus})
+	}
+	switch status.Status {
+	case PaymentApproved:
+		if order.Status == OrderCreated {
+			if err := UpdateOrderStatus(DB, order.ID, OrderApproved, ""); err != nil {
+				log.Printf("Erreur mise à jour commande %d: %v", order.ID, err)
+			}
+			order.Status = OrderApproved
+		}
+	case PaymentDeclined, PaymentVoided:
+		s.failOrder(order, "paiement "+strings.ToLower(status.Status))
+		return fmt.Errorf("commande %d: paiement %s", order.ID, status.Status)
+	default:
+		return ErrPaymentNotApproved
+	}
+
+	if err := HoldSeats(DB, *order, time.Now(), SeatHoldTTL); err != nil {
+		if errors.Is(err, ErrSoldOut) {
+			s.failOrder(order, err.Error())
+		}
+		return err
+	}
+	capture, err := s.payments.CaptureOrder(ctx, order.ProviderOrderID)
+	if err != nil {
+		// La capture a pu aboutir malgré l'erreur (délai dépassé, réponse
+		// perdue): la commande n'est passée en échec que si le prestataire
+		// confirme qu'aucune capture n'existe.
+		status, statusErr := s.payments.OrderStatus(ctx, order.ProviderOrderID)
+		if statusErr != nil {
+			return fmt.Errorf("capture commande %d: %w (état inconnu: %v)", order.ID, err, statusErr)
+		}
+		if status.CaptureID != "" {
+			return s.applyCapture(order, PaymentCapture{ID: status.CaptureID, Status: status.CaptureStatus})
+		}
+		s.failOrder(order, err.Error())
+		return err
+	}
+	return s.applyCapture(order, capture)
+}
+
+// applyCapture enregistre le résultat d'une capture: billets émis si elle est
+// encaissée, commande en attente si elle est en cours, échec si elle a été
+// refusée.
+func (s *Server) applyCapture(order *Order, capture PaymentCapture) error {
+	switch capture.Status {
+	case PaymentCompleted:
+		return CaptureOrder(DB, order, capture.ID)
+	case PaymentDeclined, PaymentFailed:
+		s.failOrder(order, "capture "+strings.ToLower(capture.Status))
+		return fmt.Errorf("commande %d: capture %s", order.ID, capture.Status)
+	default:
+		// Capture en attente chez le prestataire (PENDING): les billets
+		// seront émis quand elle sera encaissée.
+		return MarkCapturePending(DB, order, capture.ID)
+	}
+}
+
+// refundOrder rembourse l'intégralité d'une commande chez le prestataire
+// puis enregistre le remboursement.
+func (s *Server) refundOrder(ctx context.Context, order *Order) error {
+	if order.Provider != s.payments.Name() {
+		return fmt.Errorf("commande %d créée chez %s, prestataire actif: %s", order.ID, order.Provider, s.payments.Name())
+	}
+	if _, err := s.payments.Refund(ctx, order.CaptureID, order.Total, order.Currency); err != nil {
+		return fmt.Errorf("remboursement commande %d: %w", order.ID, err)
+	}
+	if err := RefundOrder(DB, order); err != nil {
+		// Le prestataire a déjà remboursé: l'incohérence doit être corrigée
+		// à la main.
+		log.Printf("Commande %d remboursée chez %s mais non enregistrée: %v", order.ID, s.payments.Name(), err)
+		return err
+	}
+	return nil
+}
+
+// failOrder passe la commande en échec en journalisant l'erreur éventuelle.
+func (s *Server) failOrder(order *Order, errMsg string) {
+	if err := FailOrder(DB, order, errMsg); err != nil {
+		log.Printf("Erreur mise à jour commande %d: %v", order.ID, err)
+	}
+	order.Status = OrderFailed
+}
+
+// newTicketCode génère un code de billet lisible ("GT-1A2B-3C4D-5E6F").
+func newTicketCode() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("génération code billet: %w", err)
+	}
+	code := strings.ToUpper(hex.EncodeToString(buf))
+	return "GT-" + code[0:4] + "-" + code[4:8] + "-" + code[8:12], nil
+}
+
+// GetOrder renvoie une commande par son identifiant.
+func GetOrder(db *sql.DB, orderID int64) (Order, error) {
+	order, err := scanOrder(db.QueryRow(`SELECT `+orderColumns+` FROM orders WHERE id = ?`, orderID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return Order{}, fmt.Errorf("lecture commande: %w", err)
+	}
+	return order, nil
+}
+
+const orderColumns = `id, user_id, provider, COALESCE(provider_order_id, ''), artist_id, artist_name, location, concert_date,
+quantity, unit_price, total, currency, status, capture_id, error, created_at, updated_at`
+
+func scanOrder(row interface{ Scan(...interface{}) error }) (Order, error) {
+	var o Order
+	err := row.Scan(&o.ID, &o.UserID, &o.Provider, &o.ProviderOrderID, &o.ArtistID, &o.ArtistName, &o.Location, &o.ConcertDate,
+		&o.Quantity, &o.UnitPrice, &o.Total, &o.Currency, &o.Status, &o.CaptureID, &o.Error, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+// GetOrderByProviderID retrouve une commande par l'identifiant du
+// prestataire; userID restreint la recherche aux commandes de l'utilisateur.
+func GetOrderByProviderID(db *sql.DB, providerOrderID string, userID int) (Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE provider_order_id = ? AND user_id = ? LIMIT 1`
+	order, err := scanOrder(db.QueryRow(query, providerOrderID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return Order{}, fmt.Errorf("lecture commande: %w", err)
+	}
+	return order, nil
+}
+
+// ListUserOrders renvoie les commandes d'un utilisateur, plus récentes en
+// premier.
+func ListUserOrders(db *sql.DB, userID int) ([]Order, error) {
+	rows, err := db.Query(`SELECT `+orderColumns+` FROM orders WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lecture commandes: %w", err)
+	}
+	defer rows.Close()
+	var orders []Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("lecture commandes: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// ListUserTickets renvoie les billets d'un utilisateur.
+func ListUserTickets(db *sql.DB, userID int) ([]Ticket, error) {
+	const query = `SELECT t.id, t.order_id, t.code, t.artist_id, o.artist_name, t.location, t.concert_date, t.status, t.created_at
+FROM tickets t JOIN orders o ON o.id = t.order_id
+WHERE t.user_id = ? ORDER BY t.created_at DESC, t.id`
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("lecture billets: %w", err)
+	}
+	defer rows.Close()
+	var tickets []Ticket
+	for rows.Next() {
+		var t Ticket
+		if err := rows.Scan(&t.ID, &t.OrderID, &t.Code, &t.ArtistID, &t.ArtistName, &t.Location, &t.ConcertDate, &t.Status, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("lecture billets: %w", err)
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
+
+// HandleMyTickets affiche les commandes et billets de l'utilisateur.
+func (s *Server) HandleMyTickets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := sessionUserID(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	orders, err := ListUserOrders(DB, userID)
+	if err != nil {
+		log.Printf("Erreur lecture commandes: %v", err)
+		http.Error(w, "Erreur lors de la récupération des commandes", http.StatusInternalServerError)
+		return
+	}
+	tickets, err := ListUserTickets(DB, userID)
+	if err != nil {
+		log.Printf("Erreur lecture billets: %v", err)
+		http.Error(w, "Erreur lors de la récupération des billets", http.StatusInternalServerError)
+		return
+	}
+	s.Render(w, "tickets.html", TicketsPageData{
+		Orders:  orders,
+		Tickets: tickets,
+		Message: r.URL.Query().Get("message"),
+		User:    currentUserProfile(r),
+	})
+}
+
+// HandleRefundOrder rembourse une commande payée: l'acheteur avant le jour
+// du concert, un administrateur à tout moment.
+func (s *Server) HandleRefundOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := sessionUserID(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Identifiant invalide", http.StatusBadRequest)
+		return
+	}
+	order, err := GetOrder(DB, id)
+	admin := IsAdmin(r)
+	if errors.Is(err, ErrOrderNotFound) || (err == nil && order.UserID != userID && !admin) {
+		http.Error(w, "Commande introuvable", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lecture commande: %v", err)
+		http.Error(w, "Erreur lors du remboursement", http.StatusInternalServerError)
+		return
+	}
+	if err := refundAllowed(order, time.Now(), admin); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err := s.refundOrder(r.Context(), &order); err != nil {
+		log.Printf("Erreur remboursement: %v", err)
+		http.Error(w, "Erreur lors du remboursement", http.StatusInternalServerError)
+		return
+	}
+	message := url.Values{"message": {fmt.Sprintf("Commande n°%d remboursée", order.ID)}}
+	http.Redirect(w, r, "/profile/tickets?"+message.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,197 @@
+package src
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingDB est une base factice qui enregistre les requêtes exécutées;
+// chaque Exec affecte rowsAffected lignes.
+type recordingDB struct {
+	mu           sync.Mutex
+	execs        []recordedExec
+	rowsAffected int64
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDB) Connect(context.Context) (driver.Conn, error) { return recordingConn{d}, nil }
+func (d *recordingDB) Driver() driver.Driver                        { return nil }
+
+// find renvoie la première requête contenant fragment.
+func (d *recordingDB) find(fragment string) (recordedExec, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.execs {
+		if strings.Contains(e.query, fragment) {
+			return e, true
+		}
+	}
+	return recordedExec{}, false
+}
+
+type recordingConn struct{ db *recordingDB }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.db, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+type recordingStmt struct {
+	db    *recordingDB
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(s.db.rowsAffected), nil
+}
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("requête non prise en charge")
+}
+
+// useRecordingDB remplace DB le temps du test.
+func useRecordingDB(t *testing.T, rowsAffected int64) *recordingDB {
+	t.Helper()
+	fake := &recordingDB{rowsAffected: rowsAffected}
+	previous := DB
+	DB = sql.OpenDB(fake)
+	t.Cleanup(func() {
+		DB.Close()
+		DB = previous
+	})
+	return fake
+}
+
+// capturedFakeOrder crée une commande payée chez la passerelle locale.
+func capturedFakeOrder(t *testing.T, provider *FakePaymentProvider, total float64) Order {
+	t.Helper()
+	ctx := context.Background()
+	payment, err := provider.CreateOrder(ctx, PaymentRequest{Amount: total, Currency: "EUR", ReturnURL: "http://localhost/paypal/success", CancelURL: "http://localhost/paypal/cancel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.decide(payment.ID, "approve"); err != nil {
+		t.Fatal(err)
+	}
+	capture, err := provider.CaptureOrder(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Order{
+		ID:              42,
+		UserID:          1,
+		Provider:        provider.Name(),
+		ProviderOrderID: payment.ID,
+		Quantity:        2,
+		Total:           total,
+		Currency:        "EUR",
+		Status:          OrderCaptured,
+		CaptureID:       capture.ID,
+	}
+}
+
+func TestRefundAllowed(t *testing.T) {
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	paid := Order{Status: OrderCaptured, CaptureID: "CAP-1", ConcertDate: "20-10-2026"}
+	past := paid
+	past.ConcertDate = "16-10-2026"
+	pending := paid
+	pending.Status = OrderApproved
+	refunded := paid
+	refunded.Status = OrderRefunded
+
+	tests := []struct {
+		name    string
+		order   Order
+		admin   bool
+		allowed bool
+	}{
+		{"acheteur avant le concert", paid, false, true},
+		{"acheteur le jour du concert", past, false, false},
+		{"administrateur après le concert", past, true, true},
+		{"capture en attente", pending, true, false},
+		{"déjà remboursée", refunded, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := refundAllowed(tt.order, now, tt.admin)
+			if (err == nil) != tt.allowed {
+				t.Errorf("refundAllowed() = %v, autorisé attendu: %v", err, tt.allowed)
+			}
+			if err != nil && !errors.Is(err, ErrNotRefundable) {
+				t.Errorf("refundAllowed() = %v, attendu ErrNotRefundable", err)
+			}
+		})
+	}
+}
+
+func TestRefundOrder(t *testing.T) {
+	fake := useRecordingDB(t, 1)
+	provider := NewFakePaymentProvider()
+	s := &Server{payments: provider}
+	order := capturedFakeOrder(t, provider, 99.98)
+
+	if err := s.refundOrder(context.Background(), &order); err != nil {
+		t.Fatalf("refundOrder() erreur: %v", err)
+	}
+	if order.Status != OrderRefunded {
+		t.Errorf("statut = %s, attendu %s", order.Status, OrderRefunded)
+	}
+	payment, _ := provider.Payment(order.ProviderOrderID)
+	if payment.Refunded != order.Total {
+		t.Errorf("montant remboursé chez le prestataire = %.2f, attendu %.2f", payment.Refunded, order.Total)
+	}
+
+	checks := []struct {
+		fragment string
+		status   string
+	}{
+		{"UPDATE orders SET status", OrderRefunded},
+		{"UPDATE tickets SET status", TicketVoid},
+		{"UPDATE seat_holds SET status", HoldReleased},
+	}
+	for _, c := range checks {
+		exec, ok := fake.find(c.fragment)
+		if !ok {
+			t.Errorf("requête %q non exécutée", c.fragment)
+			continue
+		}
+		if len(exec.args) == 0 || exec.args[0] != c.status {
+			t.Errorf("%s: arguments %v, statut attendu %s", c.fragment, exec.args, c.status)
+		}
+	}
+
+	// Un second remboursement dépasserait le montant encaissé
+	order.Status = OrderCaptured
+	if err := s.refundOrder(context.Background(), &order); err == nil {
+		t.Error("un second remboursement complet doit échouer")
+	}
+}
+
+func TestRefundOrderNotCaptured(t *testing.T) {
+	useRecordingDB(t, 0)
+	err := RefundOrder(DB, &Order{ID: 7})
+	if !errors.Is(err, ErrNotRefundable) {
+		t.Errorf("RefundOrder() = %v, attendu ErrNotRefundable", err)
+	}
+}
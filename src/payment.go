@@ -0,0 +1,339 @@
+package src
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuts renvoyés par les prestataires de paiement, repris de l'API PayPal.
+const (
+	PaymentCreated   = "CREATED"
+	PaymentApproved  = "APPROVED"
+	PaymentCompleted = "COMPLETED"
+	PaymentDeclined  = "DECLINED"
+	PaymentVoided    = "VOIDED"
+	PaymentPending   = "PENDING" // capture acceptée, encaissement en attente
+	PaymentFailed    = "FAILED"
+)
+
+// PaymentRequest décrit le paiement à créer chez le prestataire.
+type PaymentRequest struct {
+	Amount      float64
+	Currency    string
+	Description string
+	ReturnURL   string // page de retour après approbation par l'acheteur
+	CancelURL   string // page de retour après abandon
+}
+
+// PaymentOrder est une commande créée chez le prestataire; l'acheteur
+// l'approuve sur ApproveURL.
+type PaymentOrder struct {
+	ID         string
+	Status     string
+	ApproveURL string
+}
+
+// PaymentCapture est le résultat de l'encaissement d'une commande approuvée.
+type PaymentCapture struct {
+	ID     string
+	Status string
+}
+
+// PaymentStatus est l'état d'une commande chez le prestataire. CaptureID
+// n'est renseigné qu'une fois la capture demandée; CaptureStatus vaut alors
+// COMPLETED, PENDING, DECLINED ou FAILED.
+type PaymentStatus struct {
+	Status        string
+	CaptureID     string
+	CaptureStatus string
+}
+
+// PaymentRefund est le résultat d'un remboursement.
+type PaymentRefund struct {
+	ID     string
+	Status string
+}
+
+// PaymentProvider encaisse les commandes de billets. Le parcours est celui
+// de PayPal: création, approbation par l'acheteur sur la page du
+// prestataire, retour sur ReturnURL avec ?token=<ID>, puis capture.
+type PaymentProvider interface {
+	Name() string
+	CreateOrder(ctx context.Context, req PaymentRequest) (PaymentOrder, error)
+	CaptureOrder(ctx context.Context, orderID string) (PaymentCapture, error)
+	Refund(ctx context.Context, captureID string, amount float64, currency string) (PaymentRefund, error)
+	OrderStatus(ctx context.Context, orderID string) (PaymentStatus, error)
+	// VoidOrder annule une commande non capturée: l'acheteur ne peut plus
+	// l'approuver.
+	VoidOrder(ctx context.Context, orderID string) error
+}
+
+// NewPaymentProvider construit le prestataire demandé: "paypal" ou "fake"
+// (passerelle locale, sans réseau, pour le développement).
+func NewPaymentProvider(kind string, client *http.Client) (PaymentProvider, error) {
+	switch strings.ToLower(kind) {
+	case "", "paypal":
+		return &PayPalProvider{client: client}, nil
+	case "fake":
+		return NewFakePaymentProvider(), nil
+	default:
+		return nil, fmt.Errorf("prestataire de paiement inconnu: %s", kind)
+	}
+}
+
+// payPalClientID renvoie l'identifiant client du SDK JavaScript PayPal, vide
+// quand un autre prestataire est actif.
+func (s *Server) payPalClientID() string {
+	if _, ok := s.payments.(*PayPalProvider); ok {
+		return PayPalClientID
+	}
+	return ""
+}
+
+// ErrPaymentNotFound signale une commande inconnue du prestataire.
+var ErrPaymentNotFound = errors.New("commande de paiement inconnue")
+
+// FakePayment est une commande de la passerelle locale.
+type FakePayment struct {
+	ID          string
+	Amount      float64
+	Currency    string
+	Description string
+	ReturnURL   string
+	CancelURL   string
+	Status      string
+	CaptureID   string
+	Refunded    float64
+	CreatedAt   time.Time
+}
+
+// FakePaymentProvider simule PayPal en mémoire. Sa page d'approbation
+// (/payments/fake/{id}) permet d'accepter, refuser ou abandonner le paiement.
+type FakePaymentProvider struct {
+	mu       sync.Mutex
+	payments map[string]*FakePayment
+	captures map[string]string // capture -> commande
+}
+
+func NewFakePaymentProvider() *FakePaymentProvider {
+	return &FakePaymentProvider{
+		payments: make(map[string]*FakePayment),
+		captures: make(map[string]string),
+	}
+}
+
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func fakePaymentID(prefix string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("génération identifiant: %w", err)
+	}
+	return prefix + strings.ToUpper(hex.EncodeToString(buf)), nil
+}
+
+// CreateOrder enregistre la commande; la page d'approbation est servie par
+// le même hôte que ReturnURL.
+func (p *FakePaymentProvider) CreateOrder(ctx context.Context, req PaymentRequest) (PaymentOrder, error) {
+	returnURL, err := url.Parse(req.ReturnURL)
+	if err != nil {
+		return PaymentOrder{}, fmt.Errorf("URL de retour invalide: %w", err)
+	}
+	id, err := fakePaymentID("FAKE-")
+	if err != nil {
+		return PaymentOrder{}, err
+	}
+	payment := &FakePayment{
+		ID:          id,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Description: req.Description,
+		ReturnURL:   req.ReturnURL,
+		CancelURL:   req.CancelURL,
+		Status:      PaymentCreated,
+		CreatedAt:   time.Now(),
+	}
+	p.mu.Lock()
+	p.payments[id] = payment
+	p.mu.Unlock()
+
+	approve := url.URL{Scheme: returnURL.Scheme, Host: returnURL.Host, Path: "/payments/fake/" + id}
+	return PaymentOrder{ID: id, Status: PaymentCreated, ApproveURL: approve.String()}, nil
+}
+
+// CaptureOrder encaisse une commande approuvée. Comme chez PayPal, une
+// commande non approuvée, refusée ou déjà encaissée ne peut pas l'être.
+func (p *FakePaymentProvider) CaptureOrder(ctx context.Context, orderID string) (PaymentCapture, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[orderID]
+	if !ok {
+		return PaymentCapture{}, ErrPaymentNotFound
+	}
+	switch payment.Status {
+	case PaymentApproved:
+	case PaymentCompleted:
+		return PaymentCapture{}, fmt.Errorf("commande %s déjà encaissée", orderID)
+	case PaymentDeclined:
+		return PaymentCapture{}, fmt.Errorf("paiement %s refusé", orderID)
+	default:
+		return PaymentCapture{}, fmt.Errorf("commande %s non approuvée (%s)", orderID, payment.Status)
+	}
+	captureID, err := fakePaymentID("FAKECAP-")
+	if err != nil {
+		return PaymentCapture{}, err
+	}
+	payment.Status = PaymentCompleted
+	payment.CaptureID = captureID
+	p.captures[captureID] = orderID
+	return PaymentCapture{ID: captureID, Status: PaymentCompleted}, nil
+}
+
+// Refund rembourse un paiement encaissé, dans la limite du montant capturé.
+func (p *FakePaymentProvider) Refund(ctx context.Context, captureID string, amount float64, currency string) (PaymentRefund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[p.captures[captureID]]
+	if !ok {
+		return PaymentRefund{}, ErrPaymentNotFound
+	}
+	if currency != payment.Currency || amount <= 0 || payment.Refunded+amount > payment.Amount+0.001 {
+		return PaymentRefund{}, fmt.Errorf("remboursement de %.2f %s impossible sur %s", amount, currency, captureID)
+	}
+	id, err := fakePaymentID("FAKEREF-")
+	if err != nil {
+		return PaymentRefund{}, err
+	}
+	payment.Refunded += amount
+	return PaymentRefund{ID: id, Status: PaymentCompleted}, nil
+}
+
+func (p *FakePaymentProvider) OrderStatus(ctx context.Context, orderID string) (PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[orderID]
+	if !ok {
+		return PaymentStatus{}, ErrPaymentNotFound
+	}
+	status := PaymentStatus{Status: payment.Status, CaptureID: payment.CaptureID}
+	if payment.CaptureID != "" {
+		status.CaptureStatus = PaymentCompleted
+	}
+	return status, nil
+}
+
+// VoidOrder annule une commande tant qu'elle n'est pas encaissée.
+func (p *FakePaymentProvider) VoidOrder(ctx context.Context, orderID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[orderID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if payment.Status == PaymentCompleted {
+		return fmt.Errorf("commande %s déjà encaissée", orderID)
+	}
+	payment.Status = PaymentVoided
+	return nil
+}
+
+// Payment renvoie une copie de la commande.
+func (p *FakePaymentProvider) Payment(orderID string) (FakePayment, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[orderID]
+	if !ok {
+		return FakePayment{}, false
+	}
+	return *payment, true
+}
+
+// decide applique le choix de l'acheteur sur la page d'approbation et
+// renvoie l'URL où le rediriger, avec ?token=<ID> comme PayPal. Seule une
+// approbation renvoie vers ReturnURL; un refus ou un abandon renvoie vers
+// CancelURL.
+func (p *FakePaymentProvider) decide(orderID, action string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[orderID]
+	if !ok {
+		return "", ErrPaymentNotFound
+	}
+	if payment.Status != PaymentCreated {
+		return "", fmt.Errorf("commande %s déjà traitée (%s)", orderID, payment.Status)
+	}
+	target := payment.ReturnURL
+	switch action {
+	case "approve":
+		payment.Status = PaymentApproved
+	case "decline":
+		payment.Status = PaymentDeclined
+		target = payment.CancelURL
+	case "cancel":
+		payment.Status = PaymentVoided
+		target = payment.CancelURL
+	default:
+		return "", fmt.Errorf("action inconnue: %s", action)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("URL de retour invalide: %w", err)
+	}
+	query := u.Query()
+	query.Set("token", orderID)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// HandleFakePayment affiche la page d'approbation de la passerelle locale.
+func (s *Server) HandleFakePayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	fake, ok := s.payments.(*FakePaymentProvider)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	payment, ok := fake.Payment(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.Render(w, "fake-payment.html", FakePaymentPageData{Payment: payment})
+}
+
+// HandleFakePaymentDecision applique le choix de l'acheteur (approuver,
+// refuser ou annuler) puis le renvoie vers le site.
+func (s *Server) HandleFakePaymentDecision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	fake, ok := s.payments.(*FakePaymentProvider)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	target, err := fake.decide(r.PathValue("id"), r.FormValue("action"))
+	if errors.Is(err, ErrPaymentNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -0,0 +1,48 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakePaymentRefund(t *testing.T) {
+	provider := NewFakePaymentProvider()
+	order := capturedFakeOrder(t, provider, 100)
+	ctx := context.Background()
+
+	steps := []struct {
+		name     string
+		amount   float64
+		currency string
+		wantErr  bool
+		refunded float64
+	}{
+		{"remboursement partiel", 30, "EUR", false, 30},
+		{"mauvaise devise", 10, "USD", true, 30},
+		{"montant nul", 0, "EUR", true, 30},
+		{"au-delà du montant encaissé", 70.01, "EUR", true, 30},
+		{"solde restant", 70, "EUR", false, 100},
+		{"déjà intégralement remboursé", 0.01, "EUR", true, 100},
+	}
+	for _, step := range steps {
+		refund, err := provider.Refund(ctx, order.CaptureID, step.amount, step.currency)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: Refund() erreur = %v, attendu erreur: %v", step.name, err, step.wantErr)
+		}
+		if err == nil && (refund.ID == "" || refund.Status != PaymentCompleted) {
+			t.Errorf("%s: remboursement %+v incomplet", step.name, refund)
+		}
+		payment, _ := provider.Payment(order.ProviderOrderID)
+		if payment.Refunded != step.refunded {
+			t.Errorf("%s: total remboursé = %.2f, attendu %.2f", step.name, payment.Refunded, step.refunded)
+		}
+	}
+
+	if _, err := provider.Refund(ctx, "FAKECAP-INCONNU", 1, "EUR"); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("Refund() d'une capture inconnue = %v, attendu ErrPaymentNotFound", err)
+	}
+	if _, err := provider.Refund(ctx, order.ProviderOrderID, 1, "EUR"); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("Refund() avec l'identifiant de commande = %v, attendu ErrPaymentNotFound", err)
+	}
+}
@@ -2,6 +2,7 @@ package src
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 // PayPalAccessToken représente le token d'accès PayPal
@@ -47,6 +50,22 @@ type PayPalOrderResponse struct {
 	ID     string   `json:"id"`
 	Status string   `json:"status"`
 	Links  []Link   `json:"links"`
+	PurchaseUnits []PayPalPurchaseUnitResult `json:"purchase_units,omitempty"`
+}
+
+// PayPalPurchaseUnitResult contient les captures d'une commande encaissée
+type PayPalPurchaseUnitResult struct {
+	Payments struct {
+		Captures []PayPalCaptureResponse `json:"captures"`
+	} `json:"payments"`
+}
+
+// firstCapture renvoie la première capture de la commande, s'il y en a une
+func (o PayPalOrderResponse) firstCapture() (PayPalCaptureResponse, bool) {
+	if len(o.PurchaseUnits) > 0 && len(o.PurchaseUnits[0].Payments.Captures) > 0 {
+		return o.PurchaseUnits[0].Payments.Captures[0], true
+	}
+	return PayPalCaptureResponse{}, false
 }
 
 type Link struct {
@@ -61,18 +80,121 @@ type PayPalCaptureResponse struct {
 	Status string `json:"status"`
 }
 
-// GetPayPalAccessToken obtient un token d'accès PayPal
-func GetPayPalAccessToken(client *http.Client) (string, error) {
+// PayPalRefundRequest représente une demande de remboursement
+type PayPalRefundRequest struct {
+	Amount Amount `json:"amount"`
+}
+
+// PayPalRefundResponse représente la réponse de remboursement
+type PayPalRefundResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// payPalTokenCache conserve le token OAuth PayPal jusqu'à peu avant son
+// expiration. Quand plusieurs requêtes le trouvent expiré en même temps, un
+// seul renouvellement est lancé et toutes attendent son résultat. Le
+// renouvellement ne dépend du contexte d'aucune requête: un client qui se
+// déconnecte n'interrompt que sa propre attente.
+type payPalTokenCache struct {
+	mu       sync.Mutex
+	token    string
+	expires  time.Time
+	inflight *payPalTokenCall
+}
+
+type payPalTokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+var payPalTokens = &payPalTokenCache{}
+
+// get renvoie le token en cache ou attend son renouvellement par fetch.
+func (c *payPalTokenCache) get(ctx context.Context, fetch func(context.Context) (PayPalAccessToken, error)) (string, error) {
+	c.mu.Lock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	call := c.inflight
+	if call == nil {
+		call = &payPalTokenCall{done: make(chan struct{})}
+		c.inflight = call
+		go c.refresh(context.WithoutCancel(ctx), call, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// refresh demande un nouveau token, dans la limite de PayPalTokenTimeout, et
+// le partage avec toutes les requêtes en attente.
+func (c *payPalTokenCache) refresh(ctx context.Context, call *payPalTokenCall, fetch func(context.Context) (PayPalAccessToken, error)) {
+	ctx, cancel := context.WithTimeout(ctx, PayPalTokenTimeout)
+	defer cancel()
+	token, err := fetch(ctx)
+	call.token, call.err = token.AccessToken, err
+
+	c.mu.Lock()
+	c.inflight = nil
+	if err == nil {
+		c.token = token.AccessToken
+		c.expires = time.Now().Add(payPalTokenLifetime(token.ExpiresIn))
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// invalidate oublie le token s'il est toujours celui en cache: un token déjà
+// renouvelé par une autre requête est conservé.
+func (c *payPalTokenCache) invalidate(token string) {
+	c.mu.Lock()
+	if c.token == token {
+		c.token = ""
+		c.expires = time.Time{}
+	}
+	c.mu.Unlock()
+}
+
+// payPalTokenLifetime renvoie la durée de réutilisation d'un token valable
+// expiresIn secondes, marge de sécurité déduite.
+func payPalTokenLifetime(expiresIn int) time.Duration {
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime > 2*PayPalTokenMargin {
+		return lifetime - PayPalTokenMargin
+	}
+	return lifetime / 2
+}
+
+// GetPayPalAccessToken obtient un token d'accès PayPal, réutilisé jusqu'à
+// peu avant son expiration
+func GetPayPalAccessToken(ctx context.Context, client *http.Client) (string, error) {
+	return payPalTokens.get(ctx, func(ctx context.Context) (PayPalAccessToken, error) {
+		return requestPayPalAccessToken(ctx, client)
+	})
+}
+
+// requestPayPalAccessToken demande un nouveau token OAuth à PayPal
+func requestPayPalAccessToken(ctx context.Context, client *http.Client) (PayPalAccessToken, error) {
+	var token PayPalAccessToken
 	if PayPalClientID == "" || PayPalSecret == "" {
-		return "", fmt.Errorf("PayPal credentials not configured")
+		return token, fmt.Errorf("PayPal credentials not configured")
 	}
 
 	data := url.Values{}
 	data.Set("grant_type", "client_credentials")
 
-	req, err := http.NewRequest("POST", PayPalBaseURL+"/v1/oauth2/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", PayPalBaseURL+"/v1/oauth2/token", strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", err
+		return token, err
 	}
 
 	req.SetBasicAuth(PayPalClientID, PayPalSecret)
@@ -80,36 +202,74 @@ func GetPayPalAccessToken(client *http.Client) (string, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return token, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("PayPal token error: %s", string(body))
+		return token, fmt.Errorf("PayPal token error: %s", string(body))
 	}
 
-	var token PayPalAccessToken
 	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
-		return "", err
+		return token, err
+	}
+	if token.AccessToken == "" {
+		return token, fmt.Errorf("PayPal token error: empty access token")
 	}
 
-	return token.AccessToken, nil
+	return token, nil
 }
 
-// CreatePayPalOrder crée une commande PayPal
-func CreatePayPalOrder(client *http.Client, amount float64, description, returnURL, cancelURL string) (*PayPalOrderResponse, error) {
-	accessToken, err := GetPayPalAccessToken(client)
-	if err != nil {
-		return nil, err
+// doPayPalRequest appelle l'API PayPal avec le token en cache. Un 401
+// signale un token révoqué ou expiré avant l'heure: il est invalidé et la
+// requête rejouée une fois avec un nouveau token.
+func doPayPalRequest(ctx context.Context, client *http.Client, method, path string, payload []byte) (int, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		accessToken, err := GetPayPalAccessToken(ctx, client)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, PayPalBaseURL+path, body)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			log.Printf("PayPal a refusé le token d'accès, renouvellement")
+			payPalTokens.invalidate(accessToken)
+			continue
+		}
+		return resp.StatusCode, respBody, nil
 	}
+}
 
+// CreatePayPalOrder crée une commande PayPal
+func CreatePayPalOrder(ctx context.Context, client *http.Client, amount float64, currency, description, returnURL, cancelURL string) (*PayPalOrderResponse, error) {
 	orderReq := PayPalOrderRequest{
 		Intent: "CAPTURE",
 		PurchaseUnits: []PurchaseUnit{
 			{
 				Amount: Amount{
-					CurrencyCode: "EUR",
+					CurrencyCode: currency,
 					Value:        fmt.Sprintf("%.2f", amount),
 				},
 				Description: description,
@@ -126,96 +286,153 @@ func CreatePayPalOrder(client *http.Client, amount float64, description, returnU
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", PayPalBaseURL+"/v2/checkout/orders", bytes.NewBuffer(jsonData))
+	status, body, err := doPayPalRequest(ctx, client, "POST", "/v2/checkout/orders", jsonData)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if status != http.StatusCreated {
+		log.Printf("PayPal order creation error: %s", string(body))
+		return nil, fmt.Errorf("PayPal order creation failed: %s", string(body))
+	}
 
-	resp, err := client.Do(req)
-	if err != nil {
+	var orderResp PayPalOrderResponse
+	if err := json.Unmarshal(body, &orderResp); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return &orderResp, nil
+}
+
+// CapturePayPalOrder capture un paiement PayPal
+func CapturePayPalOrder(ctx context.Context, client *http.Client, orderID string) (*PayPalCaptureResponse, error) {
+	status, body, err := doPayPalRequest(ctx, client, "POST", "/v2/checkout/orders/"+url.PathEscape(orderID)+"/capture", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusCreated {
-		log.Printf("PayPal order creation error: %s", string(body))
-		return nil, fmt.Errorf("PayPal order creation failed: %s", string(body))
+	if status != http.StatusCreated && status != http.StatusOK {
+		log.Printf("PayPal capture error: %s", string(body))
+		return nil, fmt.Errorf("PayPal capture failed: %s", string(body))
 	}
 
-	var orderResp PayPalOrderResponse
-	if err := json.Unmarshal(body, &orderResp); err != nil {
+	var captureResp PayPalOrderResponse
+	if err := json.Unmarshal(body, &captureResp); err != nil {
 		return nil, err
 	}
 
-	return &orderResp, nil
+	// Sans capture dans la réponse, l'identifiant et le statut de la
+	// commande ne peuvent pas en tenir lieu: l'appelant relit l'état de la
+	// commande pour retrouver la capture éventuelle.
+	capture, ok := captureResp.firstCapture()
+	if !ok {
+		return nil, fmt.Errorf("PayPal capture failed: no capture in response for order %s (%s)", captureResp.ID, captureResp.Status)
+	}
+	return &capture, nil
 }
 
-// CapturePayPalOrder capture un paiement PayPal
-func CapturePayPalOrder(client *http.Client, orderID string) (*PayPalCaptureResponse, error) {
-	accessToken, err := GetPayPalAccessToken(client)
+// RefundPayPalCapture rembourse tout ou partie d'un paiement capturé
+func RefundPayPalCapture(ctx context.Context, client *http.Client, captureID string, amount float64, currency string) (*PayPalRefundResponse, error) {
+	jsonData, err := json.Marshal(PayPalRefundRequest{
+		Amount: Amount{CurrencyCode: currency, Value: fmt.Sprintf("%.2f", amount)},
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", PayPalBaseURL+"/v2/checkout/orders/"+orderID+"/capture", nil)
+	status, body, err := doPayPalRequest(ctx, client, "POST", "/v2/payments/captures/"+url.PathEscape(captureID)+"/refund", jsonData)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if status != http.StatusCreated && status != http.StatusOK {
+		log.Printf("PayPal refund error: %s", string(body))
+		return nil, fmt.Errorf("PayPal refund failed: %s", string(body))
+	}
 
-	resp, err := client.Do(req)
-	if err != nil {
+	var refundResp PayPalRefundResponse
+	if err := json.Unmarshal(body, &refundResp); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return &refundResp, nil
+}
+
+// GetPayPalOrder lit l'état d'une commande PayPal
+func GetPayPalOrder(ctx context.Context, client *http.Client, orderID string) (*PayPalOrderResponse, error) {
+	status, body, err := doPayPalRequest(ctx, client, "GET", "/v2/checkout/orders/"+url.PathEscape(orderID), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		log.Printf("PayPal capture error: %s", string(body))
-		return nil, fmt.Errorf("PayPal capture failed: %s", string(body))
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("PayPal order lookup failed: %s", string(body))
+	}
+
+	var orderResp PayPalOrderResponse
+	if err := json.Unmarshal(body, &orderResp); err != nil {
+		return nil, err
 	}
 
-	var captureResp struct {
-		ID     string `json:"id"`
-		Status string `json:"status"`
-		PurchaseUnits []struct {
-			Payments struct {
-				Captures []struct {
-					ID     string `json:"id"`
-					Status string `json:"status"`
-				} `json:"captures"`
-			} `json:"payments"`
-		} `json:"purchase_units"`
+	return &orderResp, nil
+}
+
+// PayPalProvider passe les paiements par l'API REST de PayPal.
+type PayPalProvider struct {
+	client *http.Client
+}
+
+func (p *PayPalProvider) Name() string {
+	return "paypal"
+}
+
+func (p *PayPalProvider) CreateOrder(ctx context.Context, req PaymentRequest) (PaymentOrder, error) {
+	order, err := CreatePayPalOrder(ctx, p.client, req.Amount, req.Currency, req.Description, req.ReturnURL, req.CancelURL)
+	if err != nil {
+		return PaymentOrder{}, err
 	}
+	result := PaymentOrder{ID: order.ID, Status: order.Status}
+	for _, link := range order.Links {
+		if link.Rel == "approve" {
+			result.ApproveURL = link.Href
+			break
+		}
+	}
+	return result, nil
+}
 
-	if err := json.Unmarshal(body, &captureResp); err != nil {
-		return nil, err
+func (p *PayPalProvider) CaptureOrder(ctx context.Context, orderID string) (PaymentCapture, error) {
+	capture, err := CapturePayPalOrder(ctx, p.client, orderID)
+	if err != nil {
+		return PaymentCapture{}, err
 	}
+	return PaymentCapture{ID: capture.ID, Status: capture.Status}, nil
+}
 
-	if len(captureResp.PurchaseUnits) > 0 && len(captureResp.PurchaseUnits[0].Payments.Captures) > 0 {
-		return &PayPalCaptureResponse{
-			ID:     captureResp.PurchaseUnits[0].Payments.Captures[0].ID,
-			Status: captureResp.PurchaseUnits[0].Payments.Captures[0].Status,
-		}, nil
+func (p *PayPalProvider) Refund(ctx context.Context, captureID string, amount float64, currency string) (PaymentRefund, error) {
+	refund, err := RefundPayPalCapture(ctx, p.client, captureID, amount, currency)
+	if err != nil {
+		return PaymentRefund{}, err
 	}
+	return PaymentRefund{ID: refund.ID, Status: refund.Status}, nil
+}
 
-	return &PayPalCaptureResponse{
-		ID:     captureResp.ID,
-		Status: captureResp.Status,
-	}, nil
+func (p *PayPalProvider) OrderStatus(ctx context.Context, orderID string) (PaymentStatus, error) {
+	order, err := GetPayPalOrder(ctx, p.client, orderID)
+	if err != nil {
+		return PaymentStatus{}, err
+	}
+	status := PaymentStatus{Status: order.Status}
+	if capture, ok := order.firstCapture(); ok {
+		status.CaptureID = capture.ID
+		status.CaptureStatus = capture.Status
+	}
+	return status, nil
 }
 
+// VoidOrder ne contacte pas PayPal: l'API Orders v2 ne permet pas d'annuler
+// une commande, mais une commande jamais capturée n'est pas débitée et expire
+// d'elle-même.
+func (p *PayPalProvider) VoidOrder(ctx context.Context, orderID string) error {
+	return nil
+}
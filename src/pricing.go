@@ -0,0 +1,320 @@
+package src
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Erreurs de tarification, renvoyées telles quelles au client (400).
+var (
+	ErrUnknownConcert  = errors.New("aucun concert de cet artiste à ce lieu et à cette date")
+	ErrInvalidQuantity = errors.New("quantité de billets invalide")
+)
+
+// PriceTier est un tarif de billet. Un critère vide (ArtistID 0, Location ou
+// ConcertDate "") s'applique à tous les concerts.
+type PriceTier struct {
+	ID          int64
+	ArtistID    int
+	Location    string // clé de lieu de l'API, ex. "paris-france"
+	ConcertDate string // au format de l'API, ex. "23-08-2019"
+	Price       float64
+	MaxPerOrder int // 0: MaxTicketsPerOrder
+	Label       string
+	CreatedAt   time.Time
+}
+
+// Matches indique si le tarif s'applique au concert.
+func (t PriceTier) Matches(artistID int, location, date string) bool {
+	return (t.ArtistID == 0 || t.ArtistID == artistID) &&
+		(t.Location == "" || t.Location == location) &&
+		(t.ConcertDate == "" || t.ConcertDate == date)
+}
+
+// specificity classe les tarifs applicables: une date précise l'emporte sur
+// un lieu, qui l'emporte sur un artiste.
+func (t PriceTier) specificity() int {
+	score := 0
+	if t.ArtistID != 0 {
+		score++
+	}
+	if t.Location != "" {
+		score += 2
+	}
+	if t.ConcertDate != "" {
+		score += 4
+	}
+	return score
+}
+
+// ListPriceTiers renvoie tous les tarifs, du plus récent au plus ancien.
+func ListPriceTiers(db *sql.DB) ([]PriceTier, error) {
+	const query = `SELECT id, artist_id, location, concert_date, price, max_per_order, label, created_at
+FROM ticket_prices ORDER BY id DESC`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("lecture tarifs: %w", err)
+	}
+	defer rows.Close()
+	var tiers []PriceTier
+	for rows.Next() {
+		var t PriceTier
+		if err := rows.Scan(&t.ID, &t.ArtistID, &t.Location, &t.ConcertDate, &t.Price, &t.MaxPerOrder, &t.Label, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("lecture tarifs: %w", err)
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// SavePriceTier enregistre un nouveau tarif.
+func SavePriceTier(db *sql.DB, t PriceTier) error {
+	const query = `INSERT INTO ticket_prices (artist_id, location, concert_date, price, max_per_order, label) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := db.Exec(query, t.ArtistID, t.Location, t.ConcertDate, t.Price, t.MaxPerOrder, t.Label); err != nil {
+		return fmt.Errorf("enregistrement tarif: %w", err)
+	}
+	return nil
+}
+
+// DeletePriceTier supprime un tarif.
+func DeletePriceTier(db *sql.DB, id int64) error {
+	if _, err := db.Exec(`DELETE FROM ticket_prices WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("suppression tarif: %w", err)
+	}
+	return nil
+}
+
+// TicketQuote est le prix calculé côté serveur pour une commande.
+type TicketQuote struct {
+	ArtistID    int
+	Location    string
+	Date        string // au format de l'API
+	Quantity    int
+	UnitPrice   float64
+	Total       float64
+	Currency    string
+	MaxPerOrder int
+	TierID      int64 // 0: prix par défaut
+}
+
+// resolvePrice choisit le tarif le plus spécifique applicable au concert;
+// à spécificité égale, le plus récent. Sans tarif, DefaultTicketPrice
+// s'applique.
+func resolvePrice(tiers []PriceTier, artistID int, location, date string) (PriceTier, bool) {
+	var best PriceTier
+	found := false
+	for _, t := range tiers {
+		if !t.Matches(artistID, location, date) {
+			continue
+		}
+		if !found || t.specificity() > best.specificity() ||
+			(t.specificity() == best.specificity() && t.ID > best.ID) {
+			best = t
+			found = true
+		}
+	}
+	return best, found
+}
+
+// normalizeConcertDate accepte une date au format de l'API ("23-08-2019",
+// éventuellement préfixée de "*") ou ISO ("2019-08-23").
+func normalizeConcertDate(value string) (string, bool) {
+	date, err := ParseConcertDate(value)
+	if err != nil {
+		date, err = time.Parse(FilterDateLayout, strings.TrimSpace(value))
+		if err != nil {
+			return "", false
+		}
+	}
+	return date.Format(APIDateLayout), true
+}
+
+// findConcert vérifie que l'artiste joue bien à ce lieu à cette date.
+func findConcert(art Artist, location, date string) (Concert, bool) {
+	for _, c := range art.Concerts {
+		if c.Raw == location && c.Date.Format(APIDateLayout) == date {
+			return c, true
+		}
+	}
+	return Concert{}, false
+}
+
+// QuoteTickets valide le concert demandé et calcule le prix des billets. Le
+// total est calculé en centimes pour éviter les erreurs d'arrondi.
+func QuoteTickets(tiers []PriceTier, art Artist, location, date string, quantity int) (TicketQuote, error) {
+	location = strings.ToLower(strings.TrimSpace(location))
+	date, ok := normalizeConcertDate(date)
+	if !ok {
+		return TicketQuote{}, ErrUnknownConcert
+	}
+	if _, ok := findConcert(art, location, date); !ok {
+		return TicketQuote{}, ErrUnknownConcert
+	}
+
+	quote := TicketQuote{
+		ArtistID:    art.ID,
+		Location:    location,
+		Date:        date,
+		Quantity:    quantity,
+		UnitPrice:   DefaultTicketPrice,
+		Currency:    TicketCurrency,
+		MaxPerOrder: MaxTicketsPerOrder,
+	}
+	if tier, ok := resolvePrice(tiers, art.ID, location, date); ok {
+		quote.UnitPrice = tier.Price
+		quote.TierID = tier.ID
+		if tier.MaxPerOrder > 0 && tier.MaxPerOrder < MaxTicketsPerOrder {
+			quote.MaxPerOrder = tier.MaxPerOrder
+		}
+	}
+	if quantity < 1 || quantity > quote.MaxPerOrder {
+		return TicketQuote{}, fmt.Errorf("%w: entre 1 et %d billets par commande", ErrInvalidQuantity, quote.MaxPerOrder)
+	}
+	cents := math.Round(quote.UnitPrice * 100)
+	quote.Total = cents * float64(quantity) / 100
+	return quote, nil
+}
+
+// TicketOffer est une date en vente sur la page d'un artiste.
+type TicketOffer struct {
+	Date        string // au format de l'API
+	UnitPrice   float64
+	MaxPerOrder int
+	Remaining   int
+	SoldOut     bool
+}
+
+// TicketOffers calcule le prix de chaque concert d'un artiste, regroupés par
+// lieu.
+func TicketOffers(tiers []PriceTier, art Artist) map[string][]TicketOffer {
+	offers := make(map[string][]TicketOffer)
+	for _, c := range art.Concerts {
+		date := c.Date.Format(APIDateLayout)
+		offer := TicketOffer{Date: date, UnitPrice: DefaultTicketPrice, MaxPerOrder: MaxTicketsPerOrder}
+		if tier, ok := resolvePrice(tiers, art.ID, c.Raw, date); ok {
+			offer.UnitPrice = tier.Price
+			if tier.MaxPerOrder > 0 && tier.MaxPerOrder < MaxTicketsPerOrder {
+				offer.MaxPerOrder = tier.MaxPerOrder
+			}
+		}
+		offers[c.Raw] = append(offers[c.Raw], offer)
+	}
+	return offers
+}
+
+// HandleAdminPrices affiche les tarifs de billets et les jauges des concerts
+// (admin seulement).
+func (s *Server) HandleAdminPrices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	tiers, err := ListPriceTiers(DB)
+	if err != nil {
+		log.Printf("Erreur lecture tarifs: %v", err)
+		http.Error(w, "Erreur lors de la récupération des tarifs", http.StatusInternalServerError)
+		return
+	}
+	capacities, err := ListConcertCapacities(DB, time.Now())
+	if err != nil {
+		log.Printf("Erreur lecture jauges: %v", err)
+		http.Error(w, "Erreur lors de la récupération des jauges", http.StatusInternalServerError)
+		return
+	}
+	names := make(map[int]string)
+	artists := s.ListArtists()
+	for _, art := range artists {
+		names[art.ID] = art.Name
+	}
+	s.Render(w, "admin-prices.html", AdminPricesPageData{
+		Tiers:        tiers,
+		Capacities:   capacities,
+		Artists:      artists,
+		ArtistNames:  names,
+		DefaultPrice: DefaultTicketPrice,
+		MaxPerOrder:  MaxTicketsPerOrder,
+		Capacity:     DefaultConcertCapacity,
+		Message:      r.URL.Query().Get("message"),
+		User:         currentUserProfile(r),
+	})
+}
+
+// HandleAdminCreatePrice ajoute un tarif.
+func (s *Server) HandleAdminCreatePrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+	tier := PriceTier{
+		Location: strings.ToLower(strings.TrimSpace(r.FormValue("location"))),
+		Label:    strings.TrimSpace(r.FormValue("label")),
+	}
+	if value := strings.TrimSpace(r.FormValue("artist_id")); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 0 {
+			http.Error(w, "Artiste invalide", http.StatusBadRequest)
+			return
+		}
+		tier.ArtistID = id
+	}
+	if value := strings.TrimSpace(r.FormValue("date")); value != "" {
+		date, ok := normalizeConcertDate(value)
+		if !ok {
+			http.Error(w, "Date invalide", http.StatusBadRequest)
+			return
+		}
+		tier.ConcertDate = date
+	}
+	price, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(r.FormValue("price")), ",", ".", 1), 64)
+	if err != nil || price <= 0 {
+		http.Error(w, "Prix invalide", http.StatusBadRequest)
+		return
+	}
+	tier.Price = math.Round(price*100) / 100
+	if value := strings.TrimSpace(r.FormValue("max_per_order")); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			http.Error(w, "Limite invalide", http.StatusBadRequest)
+			return
+		}
+		tier.MaxPerOrder = limit
+	}
+	if err := SavePriceTier(DB, tier); err != nil {
+		log.Printf("Erreur enregistrement tarif: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement du tarif", http.StatusInternalServerError)
+		return
+	}
+	message := url.Values{"message": {"Tarif enregistré"}}
+	http.Redirect(w, r, "/admin/prices?"+message.Encode(), http.StatusSeeOther)
+}
+
+// HandleAdminDeletePrice supprime un tarif.
+func (s *Server) HandleAdminDeletePrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Identifiant invalide", http.StatusBadRequest)
+		return
+	}
+	if err := DeletePriceTier(DB, id); err != nil {
+		log.Printf("Erreur suppression tarif: %v", err)
+		http.Error(w, "Erreur lors de la suppression", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/prices", http.StatusSeeOther)
+}
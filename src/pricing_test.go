@@ -0,0 +1,109 @@
+package src
+
+import (
+	"errors"
+	"testing"
+)
+
+func testArtist() Artist {
+	art := Artist{
+		ID:   7,
+		Name: "Queen",
+		DatesLocations: map[string][]string{
+			"paris-france":       {"23-08-2019", "24-08-2019"},
+			"north_carolina-usa": {"*01-09-2019"},
+		},
+	}
+	art.Concerts, _ = BuildConcerts(art)
+	return art
+}
+
+func TestResolvePrice(t *testing.T) {
+	tiers := []PriceTier{
+		{ID: 1, Price: 30},
+		{ID: 2, ArtistID: 7, Price: 40},
+		{ID: 3, ArtistID: 7, Location: "paris-france", Price: 45},
+		{ID: 4, Location: "paris-france", Price: 35},
+		{ID: 5, ConcertDate: "24-08-2019", Price: 60},
+		{ID: 6, ArtistID: 7, Location: "paris-france", Price: 47},
+		{ID: 7, ArtistID: 8, ConcertDate: "01-09-2019", Price: 99},
+	}
+	tests := []struct {
+		name     string
+		artistID int
+		location string
+		date     string
+		want     int64
+	}{
+		{"tarif général", 9, "berlin-germany", "01-01-2020", 1},
+		{"artiste plutôt que général", 7, "berlin-germany", "01-01-2020", 2},
+		{"lieu plutôt qu'artiste", 9, "paris-france", "23-08-2019", 4},
+		{"artiste et lieu, le plus récent", 7, "paris-france", "23-08-2019", 6},
+		{"date plutôt qu'artiste et lieu", 7, "paris-france", "24-08-2019", 5},
+		{"tarif d'un autre artiste ignoré", 7, "north_carolina-usa", "01-09-2019", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tier, ok := resolvePrice(tiers, tt.artistID, tt.location, tt.date)
+			if !ok || tier.ID != tt.want {
+				t.Errorf("resolvePrice() = %d (%v), attendu %d", tier.ID, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := resolvePrice(nil, 7, "paris-france", "23-08-2019"); ok {
+		t.Error("resolvePrice() sans tarif ne doit rien trouver")
+	}
+}
+
+func TestQuoteTickets(t *testing.T) {
+	art := testArtist()
+	tiers := []PriceTier{
+		{ID: 1, ArtistID: 7, Location: "paris-france", Price: 19.99},
+		{ID: 2, ConcertDate: "24-08-2019", Price: 0.1, MaxPerOrder: 4},
+	}
+	tests := []struct {
+		name     string
+		location string
+		date     string
+		quantity int
+		wantErr  error
+		unit     float64
+		total    float64
+		max      int
+	}{
+		{"prix par défaut", "north_carolina-usa", "01-09-2019", 2, nil, DefaultTicketPrice, 2 * DefaultTicketPrice, MaxTicketsPerOrder},
+		{"total en centimes", "paris-france", "23-08-2019", 3, nil, 19.99, 59.97, MaxTicketsPerOrder},
+		{"arrondi des petits montants", "paris-france", "24-08-2019", 3, nil, 0.1, 0.3, 4},
+		{"date ISO et lieu en majuscules", " Paris-France ", "2019-08-23", 1, nil, 19.99, 19.99, MaxTicketsPerOrder},
+		{"quantité maximale", "north_carolina-usa", "01-09-2019", MaxTicketsPerOrder, nil, DefaultTicketPrice, MaxTicketsPerOrder * DefaultTicketPrice, MaxTicketsPerOrder},
+		{"quantité nulle", "paris-france", "23-08-2019", 0, ErrInvalidQuantity, 0, 0, 0},
+		{"quantité négative", "paris-france", "23-08-2019", -1, ErrInvalidQuantity, 0, 0, 0},
+		{"au-delà du maximum", "paris-france", "23-08-2019", MaxTicketsPerOrder + 1, ErrInvalidQuantity, 0, 0, 0},
+		{"au-delà du maximum du tarif", "paris-france", "24-08-2019", 5, ErrInvalidQuantity, 0, 0, 0},
+		{"date inconnue", "paris-france", "25-08-2019", 1, ErrUnknownConcert, 0, 0, 0},
+		{"lieu inconnu", "berlin-germany", "23-08-2019", 1, ErrUnknownConcert, 0, 0, 0},
+		{"date illisible", "paris-france", "demain", 1, ErrUnknownConcert, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := QuoteTickets(tiers, art, tt.location, tt.date, tt.quantity)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("QuoteTickets() erreur = %v, attendu %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QuoteTickets() erreur inattendue: %v", err)
+			}
+			if quote.UnitPrice != tt.unit || quote.Total != tt.total || quote.MaxPerOrder != tt.max {
+				t.Errorf("QuoteTickets() = %.2f × %d = %.2f (max %d), attendu %.2f = %.2f (max %d)",
+					quote.UnitPrice, quote.Quantity, quote.Total, quote.MaxPerOrder, tt.unit, tt.total, tt.max)
+			}
+			if quote.Currency != TicketCurrency {
+				t.Errorf("QuoteTickets() devise = %s, attendu %s", quote.Currency, TicketCurrency)
+			}
+		})
+	}
+}
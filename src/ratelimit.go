@@ -0,0 +1,105 @@
+package src
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TokenBucket limite le débit d'appels vers un service externe: un jeton
+// est ajouté toutes les interval, dans la limite de burst. Il est partagé
+// par tous les appelants du processus.
+type TokenBucket struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+	blocked  time.Time // aucun jeton délivré avant cette date (Retry-After)
+}
+
+func NewTokenBucket(interval time.Duration, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		interval: interval,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Wait bloque jusqu'à l'obtention d'un jeton ou l'annulation du contexte.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		delay := b.reserve(time.Now())
+		b.mu.Unlock()
+		if delay <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve consomme un jeton s'il y en a un et renvoie 0, sinon le délai
+// avant le prochain jeton. Doit être appelée sous b.mu.
+func (b *TokenBucket) reserve(now time.Time) time.Duration {
+	if b.interval <= 0 {
+		return 0
+	}
+	if now.Before(b.blocked) {
+		return b.blocked.Sub(now)
+	}
+	b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.interval))
+}
+
+// Pause suspend la délivrance de jetons pendant d, par exemple après une
+// réponse 429 du service.
+func (b *TokenBucket) Pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(b.blocked) {
+		b.blocked = until
+	}
+	// Un seul jeton disponible à la reprise
+	b.tokens = 1
+	b.last = b.blocked
+}
+
+// parseRetryAfter lit l'en-tête Retry-After (secondes ou date HTTP) et
+// renvoie fallback s'il est absent ou invalide.
+func parseRetryAfter(value string, now time.Time, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return fallback
+}
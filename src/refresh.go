@@ -0,0 +1,162 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RefreshStatus expose l'état du rafraîchissement du catalogue.
+type RefreshStatus struct {
+	Running     bool      `json:"running"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at"`
+	Failures    int       `json:"consecutive_failures"`
+	NextRun     time.Time `json:"next_run"`
+	Degraded    []string  `json:"degraded,omitempty"`
+	Invalid     int       `json:"invalid_concerts"`
+
+	Geocoding GeocodeWarmupStatus `json:"geocoding"`
+}
+
+// refreshCall représente une actualisation en cours, partagée entre tous
+// les appelants concurrents.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Refresher actualise périodiquement le catalogue en arrière-plan. Les
+// données précédentes restent servies pendant l'actualisation et sont
+// conservées en cas d'échec.
+type Refresher struct {
+	server   *Server
+	interval time.Duration
+	jitter   time.Duration
+
+	mu       sync.Mutex
+	status   RefreshStatus
+	inflight *refreshCall
+}
+
+func NewRefresher(server *Server, interval, jitter time.Duration) *Refresher {
+	return &Refresher{
+		server:   server,
+		interval: interval,
+		jitter:   jitter,
+	}
+}
+
+// Refresh lance une actualisation, ou attend celle déjà en cours si un autre
+// appelant l'a démarrée: une seule requête vers la source à la fois.
+func (r *Refresher) Refresh() error {
+	r.mu.Lock()
+	if call := r.inflight; call != nil {
+		r.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	r.inflight = call
+	r.status.Running = true
+	r.mu.Unlock()
+
+	call.err = r.server.RefreshData()
+
+	r.mu.Lock()
+	r.inflight = nil
+	r.status.Running = false
+	if call.err != nil {
+		r.status.LastError = call.err.Error()
+		r.status.LastErrorAt = time.Now()
+		r.status.Failures++
+	} else {
+		r.status.LastSuccess = time.Now()
+		r.status.Failures = 0
+		report := r.server.LastReport()
+		r.status.Degraded = report.DegradedFacets()
+		r.status.Invalid = len(report.Invalid)
+	}
+	r.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// Status renvoie une copie de l'état courant.
+func (r *Refresher) Status() RefreshStatus {
+	r.mu.Lock()
+	status := r.status
+	r.mu.Unlock()
+	status.Geocoding = r.server.warmer.Status()
+	return status
+}
+
+// Run planifie les actualisations jusqu'à l'annulation du contexte.
+func (r *Refresher) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	for {
+		delay := r.nextDelay()
+		r.mu.Lock()
+		r.status.NextRun = time.Now().Add(delay)
+		r.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := r.Refresh(); err != nil {
+			log.Printf("Actualisation planifiée échouée: %v", err)
+		}
+	}
+}
+
+// nextDelay calcule l'attente avant la prochaine actualisation: l'intervalle
+// nominal avec un décalage aléatoire, ou après échec un backoff exponentiel
+// depuis RefreshRetryBase, plafonné à l'intervalle. RefreshRetryBase ne
+// s'applique qu'aux échecs: un REFRESH_INTERVAL court est respecté.
+func (r *Refresher) nextDelay() time.Duration {
+	r.mu.Lock()
+	failures := r.status.Failures
+	r.mu.Unlock()
+
+	if failures > 0 {
+		backoff := RefreshRetryBase
+		for i := 1; i < failures && backoff < r.interval; i++ {
+			backoff *= 2
+		}
+		if backoff > r.interval {
+			backoff = r.interval
+		}
+		return backoff
+	}
+	delay := r.interval
+	if r.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(2*r.jitter))) - r.jitter
+	}
+	// Un décalage plus grand que l'intervalle ne doit pas rendre l'attente
+	// nulle ou négative.
+	if delay <= 0 {
+		delay = r.interval
+	}
+	return delay
+}
+
+// HandleRefreshStatus renvoie l'état du rafraîchissement en JSON.
+func (s *Server) HandleRefreshStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.refresher.Status())
+}
@@ -0,0 +1,246 @@
+package src
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SearchField identifie le champ d'un artiste qui a produit une
+// correspondance; chaque champ a son propre poids dans le classement.
+type SearchField int
+
+const (
+	FieldName SearchField = iota
+	FieldMember
+	FieldLocation
+	FieldAlbum
+	FieldCreation
+)
+
+var fieldWeights = map[SearchField]float64{
+	FieldName:     10,
+	FieldMember:   6,
+	FieldLocation: 3,
+	FieldAlbum:    2,
+	FieldCreation: 2,
+}
+
+// posting relie un terme de l'index à un artiste.
+type posting struct {
+	artistID int
+	field    SearchField
+}
+
+// SearchResult est un artiste trouvé avec son score de pertinence. Fuzzy
+// indique qu'au moins un mot n'a été trouvé que par approximation.
+type SearchResult struct {
+	Artist Artist
+	Score  float64
+	Fuzzy  bool
+}
+
+// SearchIndex est un index inversé du catalogue, reconstruit à chaque
+// actualisation et en lecture seule ensuite.
+type SearchIndex struct {
+	artists  []Artist
+	byID     map[int]int
+	postings map[string][]posting
+	terms    []string            // vocabulaire trié, pour la recherche par préfixe
+	trigrams map[string][]string // trigramme -> termes approchables (noms, membres, lieux)
+
+	suggestions     []suggestEntry   // propositions de la recherche instantanée
+	suggestPostings map[string][]int // terme -> indices dans suggestions
+	suggestTerms    []string         // vocabulaire trié des propositions
+}
+
+// BuildSearchIndex indexe noms, membres, lieux de concert, année du premier
+// album et année de création.
+func BuildSearchIndex(artists []Artist) *SearchIndex {
+	idx := &SearchIndex{
+		artists:  artists,
+		byID:     make(map[int]int, len(artists)),
+		postings: make(map[string][]posting),
+		trigrams: make(map[string][]string),
+	}
+	for i, art := range artists {
+		idx.byID[art.ID] = i
+		idx.add(art.ID, FieldName, art.Name)
+		for _, member := range art.Members {
+			idx.add(art.ID, FieldMember, member)
+		}
+		for _, loc := range art.Locations {
+			idx.add(art.ID, FieldLocation, strings.ReplaceAll(loc, "_", " "))
+		}
+		if year := FirstAlbumYear(art); year > 0 {
+			idx.add(art.ID, FieldAlbum, strconv.Itoa(year))
+		}
+		idx.add(art.ID, FieldCreation, strconv.Itoa(art.CreationDate))
+	}
+	idx.terms = sortedTerms(idx.postings)
+	for _, term := range idx.terms {
+		if !idx.isFuzzyTerm(term) {
+			continue
+		}
+		for _, gram := range trigrams(term) {
+			idx.trigrams[gram] = append(idx.trigrams[gram], term)
+		}
+	}
+	idx.buildSuggestions()
+	return idx
+}
+
+// sortedTerms renvoie les clés d'un index de termes, triées.
+func sortedTerms[V any](postings map[string]V) []string {
+	terms := make([]string, 0, len(postings))
+	for term := range postings {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// isFuzzyTerm indique si un terme provient d'un champ textuel (nom, membre,
+// lieu) et peut donc être trouvé malgré une faute de frappe.
+func (idx *SearchIndex) isFuzzyTerm(term string) bool {
+	for _, p := range idx.postings[term] {
+		if p.field == FieldName || p.field == FieldMember || p.field == FieldLocation {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *SearchIndex) add(artistID int, field SearchField, text string) {
+	for _, token := range Tokenize(text) {
+		list := idx.postings[token]
+		duplicate := false
+		for _, p := range list {
+			if p == (posting{artistID, field}) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			idx.postings[token] = append(list, posting{artistID, field})
+		}
+	}
+}
+
+// prefixTerms renvoie les termes de l'index commençant par prefix.
+func (idx *SearchIndex) prefixTerms(prefix string) []string {
+	return termsWithPrefix(idx.terms, prefix)
+}
+
+// termsWithPrefix renvoie les termes d'un vocabulaire trié commençant par
+// prefix.
+func termsWithPrefix(terms []string, prefix string) []string {
+	start := sort.SearchStrings(terms, prefix)
+	var result []string
+	for i := start; i < len(terms) && strings.HasPrefix(terms[i], prefix); i++ {
+		result = append(result, terms[i])
+	}
+	return result
+}
+
+// Search renvoie les artistes correspondant à tous les mots de la requête,
+// classés par pertinence. Un mot correspond exactement à un terme ou en est
+// le préfixe (poids réduit); à défaut, les termes proches à une ou deux
+// fautes près sont retenus avec un poids plus faible. Une requête vide
+// renvoie tout le catalogue dans l'ordre de l'API.
+func (idx *SearchIndex) Search(query string) []SearchResult {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		results := make([]SearchResult, len(idx.artists))
+		for i, art := range idx.artists {
+			results[i] = SearchResult{Artist: art}
+		}
+		return results
+	}
+
+	var scores map[int]float64
+	fuzzy := make(map[int]bool)
+	for _, token := range tokens {
+		tokenScores := make(map[int]float64)
+		for _, term := range idx.prefixTerms(token) {
+			factor := 0.5
+			if term == token {
+				factor = 1
+			}
+			idx.scoreTerm(tokenScores, term, factor)
+		}
+		if len(tokenScores) == 0 {
+			for _, match := range idx.fuzzyTerms(token) {
+				factor := fuzzyFactor / float64(match.distance)
+				idx.scoreTerm(tokenScores, match.term, factor)
+			}
+			for id := range tokenScores {
+				fuzzy[id] = true
+			}
+		}
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for id, score := range scores {
+			if extra, ok := tokenScores[id]; ok {
+				scores[id] = score + extra
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	folded := strings.Join(tokens, " ")
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		art := idx.artists[idx.byID[id]]
+		name := strings.Join(Tokenize(art.Name), " ")
+		if name == folded {
+			score += 20
+		} else if strings.HasPrefix(name, folded) {
+			score += 10
+		}
+		results = append(results, SearchResult{Artist: art, Score: score, Fuzzy: fuzzy[id]})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Artist.Name < results[j].Artist.Name
+	})
+	return results
+}
+
+// scoreTerm crédite chaque artiste lié au terme, en gardant le meilleur
+// score par artiste pour un même mot de la requête.
+func (idx *SearchIndex) scoreTerm(scores map[int]float64, term string, factor float64) {
+	for _, p := range idx.postings[term] {
+		score := fieldWeights[p.field] * factor
+		if score > scores[p.artistID] {
+			scores[p.artistID] = score
+		}
+	}
+}
+
+// FoldText met en minuscules et retire les accents ("Beyoncé" -> "beyonce").
+func FoldText(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, text)
+	if err != nil {
+		folded = text
+	}
+	return strings.ToLower(folded)
+}
+
+// Tokenize découpe un texte normalisé en mots (lettres et chiffres).
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(FoldText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -1,6 +1,7 @@
 package src
 
 import (
+	"context"
 	"html/template"
 	"log"
 	"net/http"
@@ -28,9 +29,15 @@ func init() {
 
 type Server struct {
 	client    *http.Client
+	source    ArtistSource
 	templates *template.Template
 	mu        sync.RWMutex
 	artists   []Artist
+	index     *SearchIndex
+	report    FetchReport
+	refresher *Refresher
+	warmer    *GeocodeWarmer
+	payments  PaymentProvider
 }
 
 func NewServer() (*Server, error) {
@@ -54,18 +61,34 @@ func NewServer() (*Server, error) {
 			return s[start:end]
 		},
 		"upper": strings.ToUpper,
+		"perPageOptions": func() []int {
+			return []int{ArtistsPageSize, 2 * ArtistsPageSize, 4 * ArtistsPageSize}
+		},
 		"getString": func(ns interface{}) string {
 			return ""
 		},
 	}
 	tmpl := template.Must(template.New("pages").Funcs(funcMap).ParseGlob(TemplatesDirectory))
+	client := &http.Client{
+		Timeout: ClientTimeout,
+	}
+	source, err := NewArtistSource(client)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := NewPaymentProvider(PaymentProviderKind, client)
+	if err != nil {
+		return nil, err
+	}
 	srv := &Server{
-		client: &http.Client{
-			Timeout: ClientTimeout,
-		},
+		client:    client,
+		source:    source,
 		templates: tmpl,
+		payments:  payments,
 	}
-	if err := srv.RefreshData(); err != nil {
+	srv.refresher = NewRefresher(srv, RefreshInterval, RefreshJitter)
+	srv.warmer = NewGeocodeWarmer()
+	if err := srv.refresher.Refresh(); err != nil {
 		return nil, err
 	}
 	return srv, nil
@@ -80,16 +103,45 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/home", RequireAuth(s.HandleIndex))
 	mux.HandleFunc("/profile", RequireAuth(s.HandleProfile))
 	mux.HandleFunc("/artist", RequireAuth(s.HandleArtist))
+	mux.HandleFunc("/artist/{id}/tour.json", RequireAuth(s.HandleArtistTour))
+	mux.HandleFunc("/artist/{id}/concerts.geojson", RequireAuth(s.HandleArtistGeoJSON))
+	mux.HandleFunc("/artist/{id}/concerts.kml", RequireAuth(s.HandleArtistKML))
+	mux.HandleFunc("/artist/{id}/concerts.gpx", RequireAuth(s.HandleArtistGPX))
+	mux.HandleFunc("/artist/{id}/calendar.ics", s.HandleArtistCalendar)
+	mux.HandleFunc("/calendar/{token}/favorites.ics", s.HandleFavoritesCalendar)
+	mux.HandleFunc("/favorites/toggle", RequireAuth(s.HandleToggleFavorite))
+	mux.HandleFunc("/profile/calendar/reset", RequireAuth(s.HandleResetCalendarToken))
+	mux.HandleFunc("/profile/tickets", RequireAuth(s.HandleMyTickets))
+	mux.HandleFunc("/orders/refund", RequireAuth(s.HandleRefundOrder))
 	mux.HandleFunc(RefreshPath, RequireAuth(s.HandleRefresh))
+	mux.HandleFunc(RefreshStatusPath, RequireAuth(s.HandleRefreshStatus))
+	mux.HandleFunc("/api/suggest", RequireAuth(s.HandleSuggest))
+	mux.HandleFunc("/whats-new", RequireAuth(s.HandleWhatsNew))
+	mux.HandleFunc("/api/whats-new", RequireAuth(s.HandleWhatsNewFeed))
 	mux.HandleFunc("/api/geocode", RequireAuth(s.HandleGeocode))
+	mux.HandleFunc("/map", RequireAuth(s.HandleMap))
+	mux.HandleFunc("/api/concerts.geojson", RequireAuth(s.HandleConcertsGeoJSON))
+	mux.HandleFunc("/nearby", RequireAuth(s.HandleNearby))
+	mux.HandleFunc("/api/nearby", RequireAuth(s.HandleNearbyAPI))
 	mux.HandleFunc("/api/paypal/create-order", RequireAuth(s.HandleCreateOrder))
 	mux.HandleFunc("/api/paypal/capture-order", RequireAuth(s.HandleCaptureOrder))
 	mux.HandleFunc("/paypal/success", RequireAuth(s.HandlePayPalSuccess))
+	mux.HandleFunc("/paypal/cancel", RequireAuth(s.HandlePayPalCancel))
+	mux.HandleFunc("/payments/fake/{id}", RequireAuth(s.HandleFakePayment))
+	mux.HandleFunc("/payments/fake/{id}/decision", RequireAuth(s.HandleFakePaymentDecision))
 	mux.HandleFunc("/profile/update", RequireAuth(s.HandleUpdateProfile))
 	mux.HandleFunc("/logout", s.HandleLogout)
 	mux.HandleFunc("/admin/users", RequireAdmin(s.HandleAdminUsers))
 	mux.HandleFunc("/admin/users/update-role", RequireAdmin(s.HandleAdminUpdateUserRole))
 	mux.HandleFunc("/admin/users/delete", RequireAdmin(s.HandleAdminDeleteUser))
+	mux.HandleFunc("/admin/geocodes", RequireAdmin(s.HandleAdminGeocodes))
+	mux.HandleFunc("/admin/geocodes/update", RequireAdmin(s.HandleAdminUpdateGeocode))
+	mux.HandleFunc("/admin/geocodes/delete", RequireAdmin(s.HandleAdminDeleteGeocode))
+	mux.HandleFunc("/admin/geocodes/purge", RequireAdmin(s.HandleAdminPurgeGeocodes))
+	mux.HandleFunc("/admin/prices", RequireAdmin(s.HandleAdminPrices))
+	mux.HandleFunc("/admin/prices/create", RequireAdmin(s.HandleAdminCreatePrice))
+	mux.HandleFunc("/admin/prices/delete", RequireAdmin(s.HandleAdminDeletePrice))
+	mux.HandleFunc("/admin/capacity", RequireAdmin(s.HandleAdminSetCapacity))
 	mux.HandleFunc("/legal/conditions", s.HandleLegalConditions)
 	mux.HandleFunc("/legal/privacy", s.HandleLegalPrivacy)
 	mux.HandleFunc("/legal/cookies", s.HandleLegalCookies)
@@ -104,6 +156,9 @@ func (s *Server) Start() error {
 		ReadHeaderTimeout: ReadHeaderTimeout,
 	}
 
+	go s.refresher.Run(context.Background())
+	go RunHoldSweeper(context.Background(), HoldSweepInterval)
+
 	certExists := fileExists(CertFile)
 	keyExists := fileExists(KeyFile)
 	
@@ -118,17 +173,44 @@ func (s *Server) Start() error {
 	}
 }
 
+// RefreshData recharge le catalogue depuis la source et enregistre les
+// changements par rapport à la version conservée en base, y compris au
+// démarrage. Le tout premier chargement ne produit aucun changement. Les
+// lieux sont ensuite géocodés en arrière-plan.
 func (s *Server) RefreshData() error {
-	artists, err := FetchArtistsData(s.client)
+	artists, report, err := FetchArtistsData(context.Background(), s.source)
 	if err != nil {
 		return err
 	}
+	index := BuildSearchIndex(artists)
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.artists = artists
+	s.index = index
+	s.report = report
+	s.mu.Unlock()
+
+	if DB != nil {
+		changes, err := RecordCatalogChanges(DB, artists)
+		if err != nil {
+			log.Printf("Enregistrement des nouveautés impossible: %v", err)
+		} else if len(changes) > 0 {
+			log.Printf("%d nouveauté(s) détectée(s) dans le catalogue", len(changes))
+		}
+	}
+
+	if !s.warmer.Start(artists) {
+		log.Printf("Pré-géocodage déjà en cours, lieux repris à la fin du passage")
+	}
 	return nil
 }
 
+// LastReport renvoie le rapport de la dernière actualisation réussie.
+func (s *Server) LastReport() FetchReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.report
+}
+
 func (s *Server) ListArtists() []Artist {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -137,6 +219,28 @@ func (s *Server) ListArtists() []Artist {
 	return snapshot
 }
 
+// SearchArtists interroge l'index de recherche du catalogue courant.
+func (s *Server) SearchArtists(query string) []SearchResult {
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+	if index == nil {
+		return nil
+	}
+	return index.Search(query)
+}
+
+// SuggestQuery propose une orthographe corrigée de la requête, ou "".
+func (s *Server) SuggestQuery(query string) string {
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+	if index == nil {
+		return ""
+	}
+	return index.Suggest(query)
+}
+
 func (s *Server) FindArtist(id int) (Artist, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -0,0 +1,230 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArtistSource fournit les quatre jeux de données de l'API Groupie Tracker
+// (artistes, lieux, dates, relations), quelle que soit leur provenance.
+type ArtistSource interface {
+	Name() string
+	FetchArtists(ctx context.Context) ([]Artist, error)
+	FetchLocations(ctx context.Context) (map[int][]string, error)
+	FetchDates(ctx context.Context) (map[int][]string, error)
+	FetchRelations(ctx context.Context) (map[int]map[string][]string, error)
+}
+
+// NewArtistSource choisit la source de données selon la configuration
+// (ArtistSourceKind): "remote", "snapshot" ou "memory".
+func NewArtistSource(client *http.Client) (ArtistSource, error) {
+	switch strings.ToLower(ArtistSourceKind) {
+	case "", "remote":
+		return &RemoteSource{client: client}, nil
+	case "snapshot":
+		return &SnapshotSource{Dir: ArtistSnapshotDir}, nil
+	case "memory":
+		return NewMemorySource(DefaultFixture()), nil
+	default:
+		return nil, fmt.Errorf("source de données inconnue: %s", ArtistSourceKind)
+	}
+}
+
+// RemoteSource interroge l'API Groupie Tracker hébergée sur Heroku.
+type RemoteSource struct {
+	client *http.Client
+}
+
+func (src *RemoteSource) Name() string {
+	return "remote"
+}
+
+func (src *RemoteSource) FetchArtists(ctx context.Context) ([]Artist, error) {
+	var artists []Artist
+	if err := FetchJSON(ctx, src.client, ArtistsEndpoint, &artists); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (src *RemoteSource) FetchLocations(ctx context.Context) (map[int][]string, error) {
+	return FetchLocations(ctx, src.client)
+}
+
+func (src *RemoteSource) FetchDates(ctx context.Context) (map[int][]string, error) {
+	return FetchDates(ctx, src.client)
+}
+
+func (src *RemoteSource) FetchRelations(ctx context.Context) (map[int]map[string][]string, error) {
+	return FetchRelations(ctx, src.client)
+}
+
+// SnapshotSource lit une copie locale de l'API: un dossier contenant
+// artists.json, locations.json, dates.json et relation.json au format
+// renvoyé par les endpoints distants.
+type SnapshotSource struct {
+	Dir string
+}
+
+func (src *SnapshotSource) Name() string {
+	return "snapshot"
+}
+
+func (src *SnapshotSource) readFile(name string, target interface{}) error {
+	path := filepath.Join(src.Dir, name)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("lecture snapshot %s: %w", path, err)
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(target); err != nil {
+		return fmt.Errorf("décodage snapshot %s: %w", path, err)
+	}
+	return nil
+}
+
+func (src *SnapshotSource) FetchArtists(ctx context.Context) ([]Artist, error) {
+	var artists []Artist
+	if err := src.readFile("artists.json", &artists); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (src *SnapshotSource) FetchLocations(ctx context.Context) (map[int][]string, error) {
+	var payload LocationsPayload
+	if err := src.readFile("locations.json", &payload); err != nil {
+		return nil, err
+	}
+	return payload.ByID(), nil
+}
+
+func (src *SnapshotSource) FetchDates(ctx context.Context) (map[int][]string, error) {
+	var payload DatesPayload
+	if err := src.readFile("dates.json", &payload); err != nil {
+		return nil, err
+	}
+	return payload.ByID(), nil
+}
+
+func (src *SnapshotSource) FetchRelations(ctx context.Context) (map[int]map[string][]string, error) {
+	var payload RelationsPayload
+	if err := src.readFile("relation.json", &payload); err != nil {
+		return nil, err
+	}
+	return payload.ByID(), nil
+}
+
+// MemorySource sert un jeu de données figé en mémoire (démo, tests).
+type MemorySource struct {
+	Artists   []Artist
+	Locations map[int][]string
+	Dates     map[int][]string
+	Relations map[int]map[string][]string
+}
+
+// NewMemorySource construit une source en mémoire à partir d'artistes déjà
+// complets: les lieux, dates et relations sont extraits de chaque artiste.
+func NewMemorySource(artists []Artist) *MemorySource {
+	src := &MemorySource{
+		Artists:   make([]Artist, 0, len(artists)),
+		Locations: make(map[int][]string, len(artists)),
+		Dates:     make(map[int][]string, len(artists)),
+		Relations: make(map[int]map[string][]string, len(artists)),
+	}
+	for _, art := range artists {
+		src.Locations[art.ID] = art.Locations
+		src.Dates[art.ID] = art.ConcertDates
+		src.Relations[art.ID] = art.DatesLocations
+		art.Locations = nil
+		art.ConcertDates = nil
+		art.DatesLocations = nil
+		src.Artists = append(src.Artists, art)
+	}
+	return src
+}
+
+func (src *MemorySource) Name() string {
+	return "memory"
+}
+
+func (src *MemorySource) FetchArtists(ctx context.Context) ([]Artist, error) {
+	artists := make([]Artist, len(src.Artists))
+	copy(artists, src.Artists)
+	return artists, nil
+}
+
+func (src *MemorySource) FetchLocations(ctx context.Context) (map[int][]string, error) {
+	return src.Locations, nil
+}
+
+func (src *MemorySource) FetchDates(ctx context.Context) (map[int][]string, error) {
+	return src.Dates, nil
+}
+
+func (src *MemorySource) FetchRelations(ctx context.Context) (map[int]map[string][]string, error) {
+	return src.Relations, nil
+}
+
+// DefaultFixture renvoie un petit catalogue représentatif de l'API, utilisé
+// par la source "memory".
+func DefaultFixture() []Artist {
+	return []Artist{
+		{
+			ID:           1,
+			Image:        "https://groupietrackers.herokuapp.com/api/images/queen.jpeg",
+			Name:         "Queen",
+			Members:      []string{"Freddie Mercury", "Brian May", "John Daecon", "Roger Meddows-Taylor", "Mike Grose", "Barry Mitchell", "Doug Fogie"},
+			CreationDate: 1970,
+			FirstAlbum:   "14-12-1973",
+			Locations:    []string{"north_carolina-usa", "georgia-usa", "los_angeles-usa", "saitama-japan", "osaka-japan", "nagoya-japan", "penrose-new_zealand", "dunedin-new_zealand"},
+			ConcertDates: []string{"*23-08-2019", "*22-08-2019", "*20-08-2019", "*26-01-2020", "*28-01-2020", "*30-01-2019", "*07-02-2020", "*10-02-2020"},
+			DatesLocations: map[string][]string{
+				"dunedin-new_zealand": {"10-02-2020"},
+				"georgia-usa":         {"22-08-2019"},
+				"los_angeles-usa":     {"20-08-2019"},
+				"nagoya-japan":        {"30-01-2019"},
+				"north_carolina-usa":  {"23-08-2019"},
+				"osaka-japan":         {"28-01-2020"},
+				"penrose-new_zealand": {"07-02-2020"},
+				"saitama-japan":       {"26-01-2020"},
+			},
+		},
+		{
+			ID:           2,
+			Image:        "https://groupietrackers.herokuapp.com/api/images/soja.jpeg",
+			Name:         "SOJA",
+			Members:      []string{"Jacob Hemphill", "Bob Jefferson", "Ryan \"Byrd\" Berty", "Ken Brownell", "Patrick O'Shea", "Hellman Escorcia", "Rafael Rodriguez", "Trevor Young"},
+			CreationDate: 1997,
+			FirstAlbum:   "05-06-2002",
+			Locations:    []string{"playa_del_carmen-mexico", "papeete-french_polynesia", "noumea-new_caledonia"},
+			ConcertDates: []string{"*05-12-2019", "06-12-2019", "07-12-2019", "08-12-2019", "09-12-2019", "*16-11-2019", "*15-11-2019"},
+			DatesLocations: map[string][]string{
+				"noumea-new_caledonia":     {"15-11-2019"},
+				"papeete-french_polynesia": {"16-11-2019"},
+				"playa_del_carmen-mexico":  {"05-12-2019", "06-12-2019", "07-12-2019", "08-12-2019", "09-12-2019"},
+			},
+		},
+		{
+			ID:           3,
+			Image:        "https://groupietrackers.herokuapp.com/api/images/pinkfloyd.jpeg",
+			Name:         "Pink Floyd",
+			Members:      []string{"Roger Waters", "Nick Mason", "David Gilmour", "Richard Wright", "Syd Barrett"},
+			CreationDate: 1965,
+			FirstAlbum:   "05-08-1967",
+			Locations:    []string{"mexico_city-mexico", "monterrey-mexico", "del_mar-usa", "vancouver-canada"},
+			ConcertDates: []string{"*08-01-2020", "*10-01-2020", "*30-12-2019", "*21-08-2019"},
+			DatesLocations: map[string][]string{
+				"del_mar-usa":        {"30-12-2019"},
+				"mexico_city-mexico": {"08-01-2020"},
+				"monterrey-mexico":   {"10-01-2020"},
+				"vancouver-canada":   {"21-08-2019"},
+			},
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catégories de suggestions de la barre de recherche.
+const (
+	SuggestArtist     = "artist"
+	SuggestMember     = "member"
+	SuggestLocation   = "location"
+	SuggestFirstAlbum = "first_album"
+	SuggestCreation   = "creation_date"
+)
+
+// Suggestion est une proposition typée de la recherche instantanée.
+type Suggestion struct {
+	Type       string  `json:"type"`
+	Value      string  `json:"value"`
+	Label      string  `json:"label"`
+	ArtistID   int     `json:"artist_id"`
+	ArtistName string  `json:"artist_name"`
+	URL        string  `json:"url"`
+	Score      float64 `json:"score"`
+}
+
+// suggestEntry est une proposition précalculée lors de la construction de
+// l'index; Score y vaut le poids de son champ.
+type suggestEntry struct {
+	Suggestion
+	folded string // valeur normalisée, pour favoriser les débuts de champ
+}
+
+// buildSuggestions prépare les propositions du catalogue et leur index de
+// termes, une seule fois par actualisation.
+func (idx *SearchIndex) buildSuggestions() {
+	idx.suggestPostings = make(map[string][]int)
+	add := func(art Artist, kind, value, label string, field SearchField) {
+		tokens := Tokenize(value)
+		if len(tokens) == 0 {
+			return
+		}
+		link := "/artist?id=" + strconv.Itoa(art.ID)
+		if kind == SuggestLocation {
+			link = "/home?q=" + url.QueryEscape(value)
+		}
+		entry := suggestEntry{
+			Suggestion: Suggestion{
+				Type:       kind,
+				Value:      value,
+				Label:      label,
+				ArtistID:   art.ID,
+				ArtistName: art.Name,
+				URL:        link,
+				Score:      fieldWeights[field],
+			},
+			folded: strings.Join(tokens, " "),
+		}
+		position := len(idx.suggestions)
+		idx.suggestions = append(idx.suggestions, entry)
+		for _, token := range tokens {
+			list := idx.suggestPostings[token]
+			if len(list) == 0 || list[len(list)-1] != position {
+				idx.suggestPostings[token] = append(list, position)
+			}
+		}
+	}
+
+	for _, art := range idx.artists {
+		add(art, SuggestArtist, art.Name, art.Name+" — artiste", FieldName)
+		for _, member := range art.Members {
+			add(art, SuggestMember, member, fmt.Sprintf("%s — membre de %s", member, art.Name), FieldMember)
+		}
+		for _, loc := range art.Locations {
+			pretty := FormatLocation(loc)
+			add(art, SuggestLocation, pretty, fmt.Sprintf("%s — concert de %s", pretty, art.Name), FieldLocation)
+		}
+		if art.FirstAlbum != "" {
+			album := FormatDate(art.FirstAlbum)
+			add(art, SuggestFirstAlbum, album, fmt.Sprintf("%s — premier album de %s", album, art.Name), FieldAlbum)
+		}
+		creation := strconv.Itoa(art.CreationDate)
+		add(art, SuggestCreation, creation, fmt.Sprintf("%s — création de %s", creation, art.Name), FieldCreation)
+	}
+	idx.suggestTerms = sortedTerms(idx.suggestPostings)
+}
+
+// suggestCandidates renvoie les propositions dont un mot commence par
+// prefix, par l'index de termes.
+func (idx *SearchIndex) suggestCandidates(prefix string) map[int]bool {
+	found := make(map[int]bool)
+	for _, term := range termsWithPrefix(idx.suggestTerms, prefix) {
+		for _, position := range idx.suggestPostings[term] {
+			found[position] = true
+		}
+	}
+	return found
+}
+
+// Suggestions renvoie au plus limit propositions dont chaque champ contient
+// tous les mots de la requête (en préfixe), classées par pertinence.
+func (idx *SearchIndex) Suggestions(query string, limit int) []Suggestion {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 || limit <= 0 {
+		return nil
+	}
+	folded := strings.Join(tokens, " ")
+
+	// Intersection des propositions candidates pour chaque mot recherché
+	candidates := idx.suggestCandidates(tokens[0])
+	for _, token := range tokens[1:] {
+		if len(candidates) == 0 {
+			break
+		}
+		next := idx.suggestCandidates(token)
+		for position := range candidates {
+			if !next[position] {
+				delete(candidates, position)
+			}
+		}
+	}
+
+	result := make([]Suggestion, 0, len(candidates))
+	for position := range candidates {
+		entry := idx.suggestions[position]
+		suggestion := entry.Suggestion
+		if strings.HasPrefix(entry.folded, folded) {
+			suggestion.Score *= 2
+		}
+		result = append(result, suggestion)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if result[i].Label != result[j].Label {
+			return result[i].Label < result[j].Label
+		}
+		return result[i].URL < result[j].URL
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// HandleSuggest renvoie les suggestions de la recherche instantanée en JSON.
+func (s *Server) HandleSuggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := parsePositiveInt(r.URL.Query().Get("limit"), DefaultSuggestLimit)
+	if limit > MaxSuggestLimit {
+		limit = MaxSuggestLimit
+	}
+
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+
+	suggestions := []Suggestion{}
+	if index != nil {
+		if found := index.Suggestions(query, limit); found != nil {
+			suggestions = found
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":       query,
+		"suggestions": suggestions,
+	})
+}
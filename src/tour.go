@@ -0,0 +1,95 @@
+package src
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TourStop est une étape de la tournée d'un artiste.
+type TourStop struct {
+	Date        time.Time   `json:"date"`
+	Location    string      `json:"location"`
+	Raw         string      `json:"raw"`
+	Coordinates Coordinates `json:"coordinates"`
+	Resolved    bool        `json:"resolved"`
+	Start       bool        `json:"start"`  // première étape localisée
+	LegKm       float64     `json:"leg_km"` // depuis l'étape localisée précédente
+}
+
+// Tour est l'itinéraire chronologique des concerts d'un artiste.
+type Tour struct {
+	ArtistID   int        `json:"artist_id"`
+	ArtistName string     `json:"artist"`
+	Stops      []TourStop `json:"stops"`
+	TotalKm    float64    `json:"total_km"`
+	Unresolved int        `json:"unresolved"`
+}
+
+// BuildTour ordonne les concerts d'un artiste par date et calcule la
+// distance de chaque étape à partir des coordonnées précalculées. Une étape
+// non localisée est conservée mais n'entre pas dans les distances.
+func BuildTour(art Artist) Tour {
+	tour := Tour{ArtistID: art.ID, ArtistName: art.Name, Stops: []TourStop{}}
+	var previous *Coordinates
+	for _, c := range art.Concerts {
+		stop := TourStop{
+			Date:     c.Date,
+			Location: c.Location(),
+			Raw:      c.Raw,
+		}
+		if entry, ok := CachedGeocode(c.Raw); ok && entry.Status != GeocodeFailed {
+			stop.Coordinates = entry.Coordinates
+			stop.Resolved = true
+			if previous != nil {
+				stop.LegKm = roundKm(Haversine(*previous, entry.Coordinates))
+				tour.TotalKm += stop.LegKm
+			} else {
+				stop.Start = true
+			}
+			coords := entry.Coordinates
+			previous = &coords
+		} else {
+			tour.Unresolved++
+		}
+		tour.Stops = append(tour.Stops, stop)
+	}
+	tour.TotalKm = roundKm(tour.TotalKm)
+	return tour
+}
+
+func roundKm(km float64) float64 {
+	return math.Round(km*10) / 10
+}
+
+// artistFromPath résout l'artiste désigné par le segment {id} de l'URL et
+// répond 400/404 à sa place sinon.
+func (s *Server) artistFromPath(w http.ResponseWriter, r *http.Request) (Artist, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "Identifiant invalide", http.StatusBadRequest)
+		return Artist{}, false
+	}
+	art, ok := s.FindArtist(id)
+	if !ok {
+		http.NotFound(w, r)
+		return Artist{}, false
+	}
+	return art, true
+}
+
+// HandleArtistTour renvoie l'itinéraire de tournée d'un artiste en JSON.
+func (s *Server) HandleArtistTour(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non supportée", http.StatusMethodNotAllowed)
+		return
+	}
+	art, ok := s.artistFromPath(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BuildTour(art))
+}
@@ -1,23 +1,29 @@
 package src
 
 import (
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func BuildLocationDates(relations map[string][]string) []LocationDates {
-	if len(relations) == 0 {
+// BuildLocationDates regroupe les concerts par lieu, par ordre alphabétique.
+func BuildLocationDates(concerts []Concert) []LocationDates {
+	if len(concerts) == 0 {
 		return nil
 	}
-	result := make([]LocationDates, 0, len(relations))
-	for location, dates := range relations {
-		cleaned := CleanDates(dates)
+	byLocation := make(map[string][]Concert)
+	for _, c := range concerts {
+		byLocation[c.Raw] = append(byLocation[c.Raw], c)
+	}
+	result := make([]LocationDates, 0, len(byLocation))
+	for location, group := range byLocation {
 		result = append(result, LocationDates{
-			Raw:    location,
-			Pretty: FormatLocation(location),
-			Dates:  cleaned,
-			Count:  len(cleaned),
+			Raw:      location,
+			Pretty:   FormatLocation(location),
+			Concerts: group,
+			Count:    len(group),
 		})
 	}
 	sort.Slice(result, func(i, j int) bool {
@@ -26,43 +32,6 @@ func BuildLocationDates(relations map[string][]string) []LocationDates {
 	return result
 }
 
-func FilterArtists(artists []Artist, query string) []Artist {
-	if query == "" {
-		return artists
-	}
-	lower := strings.ToLower(query)
-	matches := make([]Artist, 0, len(artists))
-	for _, art := range artists {
-		if ArtistMatches(art, lower) {
-			matches = append(matches, art)
-		}
-	}
-	return matches
-}
-
-func ArtistMatches(art Artist, needle string) bool {
-	if strings.Contains(strings.ToLower(art.Name), needle) {
-		return true
-	}
-	for _, member := range art.Members {
-		if strings.Contains(strings.ToLower(member), needle) {
-			return true
-		}
-	}
-	if strings.Contains(strconv.Itoa(art.CreationDate), needle) {
-		return true
-	}
-	if strings.Contains(strings.ToLower(art.FirstAlbum), needle) {
-		return true
-	}
-	for _, location := range art.Locations {
-		if strings.Contains(strings.ToLower(location), needle) {
-			return true
-		}
-	}
-	return false
-}
-
 func CleanDates(values []string) []string {
 	if len(values) == 0 {
 		return values
@@ -109,3 +78,415 @@ func Capitalize(input string) string {
 	}
 	return strings.Join(words, " ")
 }
+
+// ArtistFilters regroupe les filtres de la page d'accueil. Une borne à 0 ou
+// une liste vide désactive le filtre correspondant.
+type ArtistFilters struct {
+	CreationMin int
+	CreationMax int
+	AlbumMin    int
+	AlbumMax    int
+	Members     []int
+	Countries   []string
+	Locations   []string
+}
+
+// FacetCount est une valeur de filtre avec le nombre d'artistes concernés.
+type FacetCount struct {
+	Value    string
+	Label    string
+	Count    int
+	Selected bool
+}
+
+// Facets décrit les filtres disponibles pour la sélection courante.
+type Facets struct {
+	Members      []FacetCount
+	Countries    []FacetCount
+	Locations    []FacetCount
+	CreationLow  int
+	CreationHigh int
+	AlbumLow     int
+	AlbumHigh    int
+}
+
+// ParseArtistFilters lit les filtres depuis les paramètres de l'URL
+// (creation_min, creation_max, album_min, album_max, members, country,
+// location).
+func ParseArtistFilters(values url.Values) ArtistFilters {
+	filters := ArtistFilters{
+		CreationMin: parsePositiveInt(values.Get("creation_min"), 0),
+		CreationMax: parsePositiveInt(values.Get("creation_max"), 0),
+		AlbumMin:    parsePositiveInt(values.Get("album_min"), 0),
+		AlbumMax:    parsePositiveInt(values.Get("album_max"), 0),
+	}
+	for _, v := range values["members"] {
+		if n := parsePositiveInt(v, 0); n > 0 {
+			filters.Members = append(filters.Members, n)
+		}
+	}
+	for _, v := range values["country"] {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			filters.Countries = append(filters.Countries, v)
+		}
+	}
+	for _, v := range values["location"] {
+		if v = strings.TrimSpace(v); v != "" {
+			filters.Locations = append(filters.Locations, v)
+		}
+	}
+	return filters
+}
+
+// IsZero indique qu'aucun filtre n'est actif.
+func (f ArtistFilters) IsZero() bool {
+	return f.CreationMin == 0 && f.CreationMax == 0 && f.AlbumMin == 0 && f.AlbumMax == 0 &&
+		len(f.Members) == 0 && len(f.Countries) == 0 && len(f.Locations) == 0
+}
+
+// Matches indique si l'artiste satisfait tous les filtres actifs.
+func (f ArtistFilters) Matches(art Artist) bool {
+	if f.CreationMin > 0 && art.CreationDate < f.CreationMin {
+		return false
+	}
+	if f.CreationMax > 0 && art.CreationDate > f.CreationMax {
+		return false
+	}
+	if f.AlbumMin > 0 || f.AlbumMax > 0 {
+		year := FirstAlbumYear(art)
+		if year == 0 || (f.AlbumMin > 0 && year < f.AlbumMin) || (f.AlbumMax > 0 && year > f.AlbumMax) {
+			return false
+		}
+	}
+	if len(f.Members) > 0 && !containsInt(f.Members, len(art.Members)) {
+		return false
+	}
+	if len(f.Countries) > 0 {
+		found := false
+		for _, c := range art.Concerts {
+			if containsString(f.Countries, c.Country) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Locations) > 0 {
+		found := false
+		for _, c := range art.Concerts {
+			if containsString(f.Locations, c.Raw) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyFilters renvoie les artistes satisfaisant les filtres, dans l'ordre.
+func ApplyFilters(artists []Artist, filters ArtistFilters) []Artist {
+	if filters.IsZero() {
+		return artists
+	}
+	result := make([]Artist, 0, len(artists))
+	for _, art := range artists {
+		if filters.Matches(art) {
+			result = append(result, art)
+		}
+	}
+	return result
+}
+
+// ComputeFacets calcule les compteurs de chaque filtre. Pour une facette
+// donnée, les artistes sont comptés avec tous les autres filtres appliqués
+// mais pas le sien, afin que les choix voisins restent visibles.
+// Pays et lieux proviennent tous deux des concerts, comme les filtres
+// correspondants de Matches.
+func ComputeFacets(artists []Artist, filters ArtistFilters) Facets {
+	var facets Facets
+
+	withoutMembers := filters
+	withoutMembers.Members = nil
+	memberCounts := make(map[int]int)
+	for _, art := range ApplyFilters(artists, withoutMembers) {
+		memberCounts[len(art.Members)]++
+	}
+	sizes := make([]int, 0, len(memberCounts))
+	for n := range memberCounts {
+		sizes = append(sizes, n)
+	}
+	sort.Ints(sizes)
+	for _, n := range sizes {
+		facets.Members = append(facets.Members, FacetCount{
+			Value:    strconv.Itoa(n),
+			Label:    strconv.Itoa(n),
+			Count:    memberCounts[n],
+			Selected: containsInt(filters.Members, n),
+		})
+	}
+
+	withoutCountries := filters
+	withoutCountries.Countries = nil
+	countryCounts := make(map[string]int)
+	for _, art := range ApplyFilters(artists, withoutCountries) {
+		seen := make(map[string]bool)
+		for _, c := range art.Concerts {
+			if c.Country != "" && !seen[c.Country] {
+				seen[c.Country] = true
+				countryCounts[c.Country]++
+			}
+		}
+	}
+	for code, count := range countryCounts {
+		facets.Countries = append(facets.Countries, FacetCount{
+			Value:    code,
+			Label:    CountryName(code),
+			Count:    count,
+			Selected: containsString(filters.Countries, code),
+		})
+	}
+	sortFacets(facets.Countries)
+
+	withoutLocations := filters
+	withoutLocations.Locations = nil
+	locationCounts := make(map[string]int)
+	for _, art := range ApplyFilters(artists, withoutLocations) {
+		seen := make(map[string]bool)
+		for _, c := range art.Concerts {
+			if !seen[c.Raw] {
+				seen[c.Raw] = true
+				locationCounts[c.Raw]++
+			}
+		}
+	}
+	for loc, count := range locationCounts {
+		facets.Locations = append(facets.Locations, FacetCount{
+			Value:    loc,
+			Label:    FormatLocation(loc),
+			Count:    count,
+			Selected: containsString(filters.Locations, loc),
+		})
+	}
+	sortFacets(facets.Locations)
+
+	for _, art := range artists {
+		facets.CreationLow = minPositive(facets.CreationLow, art.CreationDate)
+		facets.CreationHigh = max(facets.CreationHigh, art.CreationDate)
+		if year := FirstAlbumYear(art); year > 0 {
+			facets.AlbumLow = minPositive(facets.AlbumLow, year)
+			facets.AlbumHigh = max(facets.AlbumHigh, year)
+		}
+	}
+	return facets
+}
+
+func sortFacets(values []FacetCount) {
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Label < values[j].Label
+	})
+}
+
+// FirstAlbumYear renvoie l'année du premier album, ou 0 si la date est
+// invalide.
+func FirstAlbumYear(art Artist) int {
+	date, err := ParseConcertDate(art.FirstAlbum)
+	if err != nil {
+		return 0
+	}
+	return date.Year()
+}
+
+func minPositive(current, value int) int {
+	if current == 0 || (value > 0 && value < current) {
+		return value
+	}
+	return current
+}
+
+func containsInt(values []int, target int) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Critères de tri du catalogue.
+const (
+	SortRelevance = "relevance"
+	SortName      = "name"
+	SortCreation  = "creation"
+	SortAlbum     = "album"
+	SortConcerts  = "concerts"
+	SortNext      = "next"
+)
+
+// SortOption est un critère de tri proposé sur la page d'accueil.
+type SortOption struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+var sortLabels = []struct{ value, label string }{
+	{SortRelevance, "Pertinence"},
+	{SortName, "Nom"},
+	{SortCreation, "Date de création"},
+	{SortAlbum, "Premier album"},
+	{SortConcerts, "Nombre de concerts"},
+	{SortNext, "Prochain concert"},
+}
+
+// SortOptions renvoie les critères de tri avec le critère courant marqué.
+func SortOptions(current string) []SortOption {
+	options := make([]SortOption, len(sortLabels))
+	for i, opt := range sortLabels {
+		options[i] = SortOption{Value: opt.value, Label: opt.label, Selected: opt.value == current}
+	}
+	return options
+}
+
+// NormalizeSort valide le critère et le sens de tri demandés; par défaut,
+// la pertinence (ordre de l'API en l'absence de recherche).
+func NormalizeSort(key, order string) (string, string) {
+	valid := false
+	for _, opt := range sortLabels {
+		if opt.value == key {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		key = SortRelevance
+	}
+	if order != "desc" {
+		order = "asc"
+	}
+	return key, order
+}
+
+// SortArtists trie les artistes sur place. Le tri par pertinence conserve
+// l'ordre reçu (celui de la recherche); les égalités sont départagées par
+// nom. Pour le prochain concert, les artistes sans date à venir sont
+// toujours placés en fin de liste.
+func SortArtists(artists []Artist, key, order string, now time.Time) {
+	if key == SortRelevance {
+		return
+	}
+	desc := order == "desc"
+	compare := func(a, b Artist) int {
+		switch key {
+		case SortCreation:
+			return a.CreationDate - b.CreationDate
+		case SortAlbum:
+			return compareTimes(parseAlbumDate(a), parseAlbumDate(b))
+		case SortConcerts:
+			return len(a.Concerts) - len(b.Concerts)
+		}
+		return strings.Compare(FoldText(a.Name), FoldText(b.Name))
+	}
+	sort.SliceStable(artists, func(i, j int) bool {
+		a, b := artists[i], artists[j]
+		if key == SortNext {
+			na, okA := NextConcert(a, now)
+			nb, okB := NextConcert(b, now)
+			if okA != okB {
+				return okA
+			}
+			if okA && !na.Date.Equal(nb.Date) {
+				return na.Date.Before(nb.Date) != desc
+			}
+			return FoldText(a.Name) < FoldText(b.Name)
+		}
+		if c := compare(a, b); c != 0 {
+			return (c < 0) != desc
+		}
+		return FoldText(a.Name) < FoldText(b.Name)
+	})
+}
+
+// NextConcert renvoie le premier concert de l'artiste à partir de now.
+func NextConcert(art Artist, now time.Time) (Concert, bool) {
+	today := now.Truncate(24 * time.Hour)
+	for _, c := range art.Concerts {
+		if !c.Date.Before(today) {
+			return c, true
+		}
+	}
+	return Concert{}, false
+}
+
+func parseAlbumDate(art Artist) time.Time {
+	date, _ := ParseConcertDate(art.FirstAlbum)
+	return date
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// Pagination décrit la page courante d'une liste et les pages voisines.
+type Pagination struct {
+	Page    int
+	Pages   int
+	PerPage int
+	From    int
+	To      int
+	PrevURL string
+	NextURL string
+}
+
+// Paginate calcule les bornes de la page demandée parmi total éléments et
+// construit les liens précédent/suivant en conservant les autres paramètres
+// de la requête.
+func Paginate(total, page, perPage int, base *url.URL) Pagination {
+	pages := (total + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+	if page > pages {
+		page = pages
+	}
+	p := Pagination{
+		Page:    page,
+		Pages:   pages,
+		PerPage: perPage,
+		From:    (page - 1) * perPage,
+		To:      min(page*perPage, total),
+	}
+	link := func(target int) string {
+		query := base.Query()
+		query.Set("page", strconv.Itoa(target))
+		u := *base
+		u.RawQuery = query.Encode()
+		return u.RequestURI()
+	}
+	if page > 1 {
+		p.PrevURL = link(page - 1)
+	}
+	if page < pages {
+		p.NextURL = link(page + 1)
+	}
+	return p
+}
@@ -0,0 +1,115 @@
+package src
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GeocodeWarmupStatus expose l'avancement du pré-géocodage des lieux.
+type GeocodeWarmupStatus struct {
+	Running    bool      `json:"running"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Total      int       `json:"total"`
+	Resolved   int       `json:"resolved"`
+	Failed     int       `json:"failed"`
+}
+
+// GeocodeWarmer géocode en arrière-plan tous les lieux du catalogue après
+// chaque actualisation, pour que les pages artistes n'attendent jamais le
+// géocodeur.
+type GeocodeWarmer struct {
+	mu      sync.Mutex
+	status  GeocodeWarmupStatus
+	pending []Artist // catalogue à reprendre à la fin du passage en cours
+}
+
+func NewGeocodeWarmer() *GeocodeWarmer {
+	return &GeocodeWarmer{}
+}
+
+// Start lance le pré-géocodage des lieux du catalogue. Si un passage est
+// déjà en cours, le catalogue est mis en attente et un nouveau passage le
+// reprend dès la fin du passage courant; seul le dernier catalogue reçu est
+// conservé. Start renvoie false dans ce cas.
+func (w *GeocodeWarmer) Start(artists []Artist) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status.Running {
+		w.pending = artists
+		return false
+	}
+	addresses := CatalogAddresses(artists)
+	w.begin(addresses)
+	go w.run(addresses)
+	return true
+}
+
+// begin réinitialise l'état pour un nouveau passage; w.mu doit être tenu.
+func (w *GeocodeWarmer) begin(addresses []string) {
+	w.status = GeocodeWarmupStatus{
+		Running:   true,
+		StartedAt: time.Now(),
+		Total:     len(addresses),
+	}
+}
+
+func (w *GeocodeWarmer) run(addresses []string) {
+	for {
+		for _, address := range addresses {
+			// Les adresses déjà en cache (ou en quarantaine) sont servies
+			// sans appel au géocodeur
+			_, err := GeocodeLocation(address)
+			w.mu.Lock()
+			if err != nil {
+				w.status.Failed++
+			} else {
+				w.status.Resolved++
+			}
+			w.mu.Unlock()
+		}
+
+		w.mu.Lock()
+		w.status.Running = false
+		w.status.FinishedAt = time.Now()
+		status := w.status
+		pending := w.pending
+		w.pending = nil
+		if pending != nil {
+			addresses = CatalogAddresses(pending)
+			w.begin(addresses)
+		}
+		w.mu.Unlock()
+		log.Printf("Pré-géocodage terminé: %d/%d lieu(x) localisé(s), %d en échec", status.Resolved, status.Total, status.Failed)
+		if pending == nil {
+			return
+		}
+		log.Printf("Pré-géocodage relancé pour le dernier catalogue reçu")
+	}
+}
+
+// Status renvoie une copie de l'état courant.
+func (w *GeocodeWarmer) Status() GeocodeWarmupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// CatalogAddresses renvoie les clés de lieu distinctes de tous les concerts
+// du catalogue, triées.
+func CatalogAddresses(artists []Artist) []string {
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, art := range artists {
+		for _, c := range art.Concerts {
+			if !seen[c.Raw] {
+				seen[c.Raw] = true
+				addresses = append(addresses, c.Raw)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}